@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// Histogram archives are gzip compressed and contain, in order:
+//
+//	magic       "BAEH"
+//	version     uint16
+//	header_len  uint32
+//	header      JSON archiveHeader
+//	records     one per CalcResult, sorted by y then x
+//
+// All numbers are little endian. Each record is x, y (int32), real and imag
+// of z (float64), val (uint64) and a flags byte. The flags say whether the
+// point escaped or was periodic and whether channels (NUM_CHANNELS uint64)
+// and detail (its, real and imag of the final z, period, min modulus and min
+// its as int64/float64) follow.
+const (
+	ARCHIVE_MAGIC   = "BAEH"
+	ARCHIVE_VERSION = 1
+
+	MAX_HEADER_LEN = 1 << 20 // bytes of JSON header read from archives and stores
+)
+
+const (
+	archiveEscaped = 1 << iota
+	archivePeriodic
+	archiveChans
+	archiveDetail
+)
+
+// Archive is a histogram along with the parameters that generated it.
+type Archive struct {
+	Params  *CalcParams
+	Orbits  int
+	Results CalcResults
+
+	// Composited is the number of orbits calculated for the masked regions
+	// composited over the results. Orbits is then only for the rest.
+	Composited int
+	Composites []ArchiveComposite
+}
+
+// ArchiveComposite is a masked region composited over an archive's results,
+// with the params it was calculated with.
+type ArchiveComposite struct {
+	Mask   string      `json:"mask"`
+	Params *CalcParams `json:"params"`
+	Orbits int         `json:"orbits"`
+}
+
+// archiveHeader is the JSON header of a histogram archive.
+type archiveHeader struct {
+	Params     *CalcParams        `json:"params"`
+	Orbits     int                `json:"orbits"`
+	Composited int                `json:"composited,omitempty"`
+	Composites []ArchiveComposite `json:"composites,omitempty"`
+	Results    int                `json:"results"`
+}
+
+// WriteArchive writes the archive to path.
+func (a *Archive) WriteArchive(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if err := a.Encode(zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if a.Composited > 0 {
+		fmt.Printf("Wrote %s (%d results, %d orbits, %d composited)\n", path, len(a.Results), a.Orbits, a.Composited)
+	} else {
+		fmt.Printf("Wrote %s (%d results, %d orbits)\n", path, len(a.Results), a.Orbits)
+	}
+	return f.Close()
+}
+
+// Encode writes the uncompressed archive to w.
+func (a *Archive) Encode(w io.Writer) error {
+	header, err := json.Marshal(archiveHeader{
+		Params: a.Params, Orbits: a.Orbits, Composited: a.Composited, Composites: a.Composites,
+		Results: len(a.Results)})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := []byte(ARCHIVE_MAGIC)
+	buf = binary.LittleEndian.AppendUint16(buf, ARCHIVE_VERSION)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+
+	points := make([]plane.ImagePoint, 0, len(a.Results))
+	for xy := range a.Results {
+		points = append(points, xy)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Y != points[j].Y {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+
+	for _, xy := range points {
+		r := a.Results[xy]
+		var flags byte
+		if r.Escaped {
+			flags |= archiveEscaped
+		}
+		if r.Periodic {
+			flags |= archivePeriodic
+		}
+		if r.Chans != nil {
+			flags |= archiveChans
+		}
+		if r.Detail != nil {
+			flags |= archiveDetail
+		}
+
+		buf = buf[:0]
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(xy.X)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(xy.Y)))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(real(r.Z)))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(imag(r.Z)))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(r.Val))
+		buf = append(buf, flags)
+		if r.Chans != nil {
+			for _, n := range r.Chans {
+				buf = binary.LittleEndian.AppendUint64(buf, uint64(n))
+			}
+		}
+		if d := r.Detail; d != nil {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(d.Its))
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(real(d.ZFinal)))
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(imag(d.ZFinal)))
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(d.Period))
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(d.MinMod))
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(d.MinIts))
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadArchive reads the archive at path.
+func ReadArchive(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	a, err := DecodeArchive(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+// DecodeArchive reads an uncompressed archive from r.
+func DecodeArchive(r io.Reader) (*Archive, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(ARCHIVE_MAGIC))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, []byte(ARCHIVE_MAGIC)) {
+		return nil, fmt.Errorf("not a histogram archive")
+	}
+
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != ARCHIVE_VERSION {
+		return nil, fmt.Errorf("unsupported archive version %d", version)
+	}
+
+	var header_len uint32
+	if err := binary.Read(br, binary.LittleEndian, &header_len); err != nil {
+		return nil, err
+	}
+	if header_len > MAX_HEADER_LEN {
+		return nil, fmt.Errorf("header is %d bytes, more than %d", header_len, MAX_HEADER_LEN)
+	}
+	header_data := make([]byte, header_len)
+	if _, err := io.ReadFull(br, header_data); err != nil {
+		return nil, err
+	}
+	var header archiveHeader
+	if err := json.Unmarshal(header_data, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Params == nil {
+		return nil, fmt.Errorf("missing params")
+	}
+	a := &Archive{
+		Params: header.Params, Orbits: header.Orbits, Composited: header.Composited,
+		Composites: header.Composites, Results: make(CalcResults)}
+	ar := archiveReader{r: br}
+	for n := 0; n < header.Results && ar.err == nil; n++ {
+		x, y := int(int32(ar.u32())), int(int32(ar.u32()))
+		result := &CalcResult{Z: ar.c128(), Val: uint(ar.u64())}
+
+		flags := ar.u8()
+		result.Escaped = flags&archiveEscaped != 0
+		result.Periodic = flags&archivePeriodic != 0
+		if flags&archiveChans != 0 {
+			result.Chans = new(Channels)
+			for ch := range result.Chans {
+				result.Chans[ch] = uint(ar.u64())
+			}
+		}
+		if flags&archiveDetail != 0 {
+			result.Detail = &CalcDetail{
+				Its:    int(ar.u64()),
+				ZFinal: ar.c128(),
+				Period: int(ar.u64()),
+				MinMod: math.Float64frombits(ar.u64()),
+				MinIts: int(ar.u64()),
+			}
+		}
+		a.Results[plane.ImagePoint{X: x, Y: y}] = result
+	}
+	if ar.err != nil {
+		return nil, fmt.Errorf("reading results: %w", ar.err)
+	}
+	return a, nil
+}
+
+// archiveReader reads little endian values, remembering the first error.
+type archiveReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+	err error
+}
+
+func (ar *archiveReader) read(n int) []byte {
+	if ar.err != nil {
+		return ar.buf[:n]
+	}
+	_, ar.err = io.ReadFull(ar.r, ar.buf[:n])
+	return ar.buf[:n]
+}
+
+func (ar *archiveReader) u8() byte {
+	return ar.read(1)[0]
+}
+
+func (ar *archiveReader) u32() uint32 {
+	return binary.LittleEndian.Uint32(ar.read(4))
+}
+
+func (ar *archiveReader) u64() uint64 {
+	return binary.LittleEndian.Uint64(ar.read(8))
+}
+
+func (ar *archiveReader) c128() complex128 {
+	r := math.Float64frombits(ar.u64())
+	return complex(r, math.Float64frombits(ar.u64()))
+}
+
+// Compatible returns an error if the archives' histograms can't be merged.
+func (a *Archive) Compatible(b *Archive) error {
+	ap, bp := a.Params, b.Params
+	switch {
+	case !ap.Style.Accumulates() || !bp.Style.Accumulates():
+		return fmt.Errorf("only accumulating styles can be merged: %v, %v", ap.Style, bp.Style)
+	case a.Composited > 0 || b.Composited > 0:
+		return fmt.Errorf("composited archives can't be merged")
+	}
+	return ap.sameCalculation(bp)
+}
+
+// sameCalculation returns an error if the params calculate different
+// histograms, other than in how many orbits there are.
+func (ap *CalcParams) sameCalculation(bp *CalcParams) error {
+	if err := ap.sameFrame(bp); err != nil {
+		return err
+	}
+	switch {
+	case ap.ZF.Name != bp.ZF.Name:
+		return fmt.Errorf("zfuncs differ: %v != %v", ap.ZF.Name, bp.ZF.Name)
+	case ap.C != bp.C:
+		return fmt.Errorf("c differs: %v != %v", ap.C, bp.C)
+	case ap.Iterations != bp.Iterations:
+		return fmt.Errorf("iterations differ: %v != %v", ap.Iterations, bp.Iterations)
+	case ap.Limit != bp.Limit:
+		return fmt.Errorf("limits differ: %v != %v", ap.Limit, bp.Limit)
+	case ap.CH.Desc != bp.CH.Desc:
+		return fmt.Errorf("channels differ: %q != %q", ap.CH.Desc, bp.CH.Desc)
+	}
+	return nil
+}
+
+// sameFrame returns an error if the params' results don't land on the same
+// pixels, so they can't be composited.
+func (ap *CalcParams) sameFrame(bp *CalcParams) error {
+	switch {
+	case ap.Style != bp.Style:
+		return fmt.Errorf("styles differ: %v != %v", ap.Style, bp.Style)
+	case !samePlane(ap.Plane, bp.Plane):
+		return fmt.Errorf("planes differ: %v != %v", ap.Plane, bp.Plane)
+	}
+	return nil
+}
+
+// samePlane returns whether two planes cover the same view with the same image.
+func samePlane(a, b *plane.Plane) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+// Merge adds the histogram from src, refusing archives that aren't compatible.
+func (a *Archive) Merge(src *Archive) error {
+	if err := a.Compatible(src); err != nil {
+		return err
+	}
+	// Copy results so src is left unchanged.
+	results := make(CalcResults, len(src.Results))
+	for xy, r := range src.Results {
+		results[xy] = r.Copy(r.Z)
+	}
+	a.Results.Merge(results)
+	a.Orbits += src.Orbits
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func testArchive() *Archive {
+	params := NewCalcParams(CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(4, 2), 16).WithInverted(),
+		Style:      Attractor,
+		ZF:         zf_klein,
+		C:          complex(-0.1278, 0.0),
+		Iterations: 64,
+		CalcArea:   plane.PlaneView{Min: complex(-0.5, -0.25), Max: complex(-0.5, 0.5)},
+		RPoints:    1,
+		IPoints:    8,
+		CF:         cf_luma_clip_percent_max,
+		CFP:        ColorFuncParams{Clip: 10, Gamma: 2},
+	})
+	results := CalcResults{
+		plane.ImagePoint{X: 1, Y: 2}: &CalcResult{Z: complex(-1, 1), Val: 7, Escaped: true},
+		plane.ImagePoint{X: 3, Y: 0}: &CalcResult{
+			Z: complex(0.5, -0.25), Val: 3, Periodic: true, Chans: &Channels{1, 0, 2}},
+		plane.ImagePoint{X: -1, Y: 5}: &CalcResult{
+			Z: complex(2, 2), Val: 1, Detail: &CalcDetail{Its: 9, ZFinal: complex(3, -4), Period: 2, MinMod: 0.5, MinIts: 4}},
+	}
+	return &Archive{Params: params, Orbits: 8, Results: results}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	expect := testArchive()
+	path := filepath.Join(t.TempDir(), "test.bae")
+	if err := expect.WriteArchive(path); err != nil {
+		t.Fatalf("WriteArchive Error: %v", err)
+	}
+	result, err := ReadArchive(path)
+	if err != nil {
+		t.Fatalf("ReadArchive Error: %v", err)
+	}
+
+	if result.Orbits != expect.Orbits {
+		t.Error(result.Orbits, expect.Orbits)
+	}
+	if result.Params.String() != expect.Params.String() {
+		t.Errorf("Params differ:\n%v\n%v", result.Params, expect.Params)
+	}
+	if len(result.Results) != len(expect.Results) {
+		t.Fatalf("len(result.Results) = %d; want %d", len(result.Results), len(expect.Results))
+	}
+	for xy, e := range expect.Results {
+		r, ok := result.Results[xy]
+		if !ok {
+			t.Fatalf("Missing result for %v", xy)
+		}
+		if r.Z != e.Z || r.Val != e.Val || r.Escaped != e.Escaped || r.Periodic != e.Periodic {
+			t.Errorf("Result at %v differs: %v != %v", xy, *r, *e)
+		}
+		if (r.Chans == nil) != (e.Chans == nil) || (r.Chans != nil && *r.Chans != *e.Chans) {
+			t.Errorf("Channels at %v differ: %v != %v", xy, r.Chans, e.Chans)
+		}
+		if (r.Detail == nil) != (e.Detail == nil) || (r.Detail != nil && *r.Detail != *e.Detail) {
+			t.Errorf("Detail at %v differs: %v != %v", xy, r.Detail, e.Detail)
+		}
+	}
+}
+
+func TestDecodeArchiveRejectsGarbage(t *testing.T) {
+	if _, err := DecodeArchive(bytes.NewReader([]byte("PNG nope"))); err == nil {
+		t.Errorf("Expected an error decoding garbage")
+	}
+}
+
+func TestDecodeArchiveRejectsHugeHeader(t *testing.T) {
+	data := []byte(ARCHIVE_MAGIC + "\x01\x00\xff\xff\xff\xff")
+	if _, err := DecodeArchive(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "header") {
+		t.Errorf("Expected an error for a 4GB header, got %v", err)
+	}
+}
+
+func TestArchiveMerge(t *testing.T) {
+	dst, src := testArchive(), testArchive()
+	if err := dst.Merge(src); err != nil {
+		t.Fatalf("Merge Error: %v", err)
+	}
+	if dst.Orbits != 16 {
+		t.Error(dst.Orbits, 16)
+	}
+	if result := dst.Results[plane.ImagePoint{X: 1, Y: 2}].Val; result != 14 {
+		t.Error(result, 14)
+	}
+	if result := src.Results[plane.ImagePoint{X: 1, Y: 2}].Val; result != 7 {
+		t.Errorf("Expected src to be unchanged, got %d", result)
+	}
+}
+
+func TestArchiveMergeMismatched(t *testing.T) {
+	testCases := []struct {
+		name   string
+		change func(cp *CalcParams)
+	}{
+		{"plane", func(cp *CalcParams) { cp.Plane = cp.Plane.NewOrigin(complex(1, 1)) }},
+		{"zfunc", func(cp *CalcParams) { cp.ZF = zf_klein2 }},
+		{"c", func(cp *CalcParams) { cp.C = complex(0, 1) }},
+		{"style", func(cp *CalcParams) { cp.Style = Julia }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, src := testArchive(), testArchive()
+			tc.change(src.Params)
+			if err := dst.Merge(src); err == nil {
+				t.Errorf("Expected merge to be refused")
+			}
+		})
+	}
+}
+
+func TestArchiveMergeMergedChannels(t *testing.T) {
+	dir := t.TempDir()
+	archive := func(name string) *Archive {
+		a := testArchive()
+		a.Params.CH = ch_nebulabrot
+		path := filepath.Join(dir, name)
+		if err := a.WriteArchive(path); err != nil {
+			t.Fatal(err)
+		}
+		result, err := ReadArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// Merge two archives, write the merged one, and merge it with another.
+	merged := archive("a.bae")
+	if err := merged.Merge(archive("b.bae")); err != nil {
+		t.Fatalf("Merge Error: %v", err)
+	}
+	path := filepath.Join(dir, "merged.bae")
+	if err := merged.WriteArchive(path); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Merge(archive("c.bae")); err != nil {
+		t.Fatalf("Merging a merged archive: %v", err)
+	}
+	if again.Orbits != 24 || again.Params.CH.F == nil || again.Params.CH.Name != ch_nebulabrot.Name {
+		t.Errorf("Expected 24 orbits of the %v, got %d of the %v", ch_nebulabrot, again.Orbits, again.Params.CH)
+	}
+}
+
+func TestArchiveUnknownChannels(t *testing.T) {
+	a := testArchive()
+	a.Params.CH = ChannelByEscapeIts(10, 20)
+	var buf bytes.Buffer
+	if err := a.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeArchive(&buf); err == nil {
+		t.Errorf("Expected an error for an unknown ChannelFunc")
+	}
+}
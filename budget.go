@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	BUDGET_BATCH   = time.Second     // how often routines hand over their results
+	BUDGET_PUBLISH = 5 * time.Second // how often intermediate results are published
+)
+
+// Budgeted returns whether the calculation runs until a time budget is spent
+// or a noise target is met rather than over a fixed problem set.
+func (cp *CalcParams) Budgeted() bool {
+	return cp.Style.Accumulates() && (cp.Budget > 0 || cp.TargetNoise > 0)
+}
+
+// Noise estimates the relative noise of the histogram. Each pixel's count is
+// Poisson distributed so its relative noise is 1/√n; the median pixel is used.
+func (cr CalcResults) Noise() float64 {
+	median := cr.Median()
+	if math.IsNaN(median) || median <= 0 {
+		return math.Inf(1)
+	}
+	return 1 / math.Sqrt(median)
+}
+
+// CalculateBudgeted calculates orbits from random points in the calc area
+// until the Budget is spent or the histogram's Noise drops to TargetNoise,
+// whichever comes first. The noise is measured at most every BUDGET_PUBLISH.
+// It returns the histogram and the number of orbits calculated. If progress
+// is not nil it's called with the results so far every BUDGET_PUBLISH.
+func (cp *CalcParams) CalculateBudgeted(progress func(CalcResults, int)) (histogram CalcResults, orbits int) {
+	concurrency := cp.Concurrency
+	if cp.Concurrency == 0 {
+		concurrency = int(1.5 * float64(runtime.NumCPU()))
+	}
+	fmt.Printf("Calculating for %v or until noise is %.4f (using %v concurrent routines)\n",
+		cp.Budget, cp.TargetNoise, concurrency)
+
+	type batch struct {
+		results CalcResults
+		orbits  int
+	}
+
+	t_start := time.Now()
+	done := make(chan struct{})
+	batch_ch := make(chan batch)
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed)) //nolint:gosec
+			for {
+				b := batch{results: make(CalcResults)}
+				t_batch := time.Now()
+				for time.Since(t_batch) < BUDGET_BATCH {
+					z := cp.CalcArea.Min + complex(
+						rng.Float64()*cp.CalcArea.RealLen(), rng.Float64()*cp.CalcArea.ImagLen())
+					cp.orbit(CalcPoint{Z: z, XY: cp.Plane.ToImagePoint(z)}, b.results)
+					b.orbits++
+				}
+				batch_ch <- b
+
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}(t_start.UnixNano() + int64(n))
+	}
+	go func() {
+		wg.Wait()
+		close(batch_ch)
+	}()
+
+	histogram = make(CalcResults)
+	stopped := false
+	t_published := t_start
+	// Measuring the noise sorts the histogram, so it's only done when there's
+	// a target, at most every BUDGET_PUBLISH.
+	noise := math.Inf(1)
+	var t_measured time.Time
+	status := func() string {
+		if cp.TargetNoise <= 0 {
+			return fmt.Sprintf("%d orbits", orbits)
+		}
+		return fmt.Sprintf("%d orbits • noise %.4f", orbits, noise)
+	}
+	for b := range batch_ch {
+		histogram.Merge(b.results)
+		orbits += b.orbits
+		if stopped {
+			continue
+		}
+
+		elapsed := time.Since(t_start)
+		if cp.TargetNoise > 0 && time.Since(t_measured) >= BUDGET_PUBLISH {
+			noise = histogram.Noise()
+			t_measured = time.Now()
+		}
+		if (cp.Budget > 0 && elapsed >= cp.Budget) || (cp.TargetNoise > 0 && noise <= cp.TargetNoise) {
+			close(done)
+			stopped = true
+			continue
+		}
+		if progress != nil && time.Since(t_published) >= BUDGET_PUBLISH {
+			fmt.Printf("[%v] ⌚️ Workin %6.0fs • %s\n", TimestampMilli(), elapsed.Seconds(), status())
+			progress(histogram, orbits)
+			t_published = time.Now()
+		}
+	}
+
+	fmt.Printf("[%v] ✅ Finish %6.0fs • %s\n", TimestampMilli(), time.Since(t_start).Seconds(), status())
+	return
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestCalcResultsNoise(t *testing.T) {
+	crs := CalcResults{
+		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{Val: 1},
+		plane.ImagePoint{X: 1, Y: 0}: &CalcResult{Val: 100},
+		plane.ImagePoint{X: 2, Y: 0}: &CalcResult{Val: 10000},
+	}
+	if result := crs.Noise(); result != 0.1 {
+		t.Errorf("Expected 0.1, got %v", result)
+	}
+	if result := make(CalcResults).Noise(); !math.IsInf(result, 1) {
+		t.Errorf("Expected +Inf for empty results, got %v", result)
+	}
+}
+
+func TestCalculateBudgetedTargetNoise(t *testing.T) {
+	params := CalcParams{
+		Plane:       plane.NewPlane(complex(0, 0), complex(2, 2), 8),
+		Style:       Attractor,
+		ZF:          zf_klein,
+		C:           complex(-0.1278, 0.0),
+		Iterations:  64,
+		Limit:       4,
+		CalcArea:    plane.PlaneView{Min: complex(-0.5, -0.5), Max: complex(0.5, 0.5)},
+		TargetNoise: 1,
+		Concurrency: 2,
+	}
+	if !params.Budgeted() {
+		t.Fatalf("Expected params to be budgeted")
+	}
+
+	histogram, orbits := params.CalculateBudgeted(nil)
+	if orbits <= 0 {
+		t.Errorf("Expected some orbits to be calculated, got %d", orbits)
+	}
+	if noise := histogram.Noise(); noise > params.TargetNoise {
+		t.Errorf("Expected noise <= %v, got %v", params.TargetNoise, noise)
+	}
+}
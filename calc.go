@@ -1,8 +1,8 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"image/color"
 	"log"
 	"math"
 	"math/cmplx"
@@ -20,12 +20,16 @@ const (
 	Attractor CalcStyle = iota
 	Julia
 	Mandelbrot
+	Buddhabrot // orbits of Mandelbrot points accumulated like an Attractor
+	Domain     // f^n(z, c) of each point, without escaping, for domain coloring
 )
 
 var CalcStyleName = map[int]string{
 	int(Attractor):  "Attractor",
 	int(Julia):      "Julia",
 	int(Mandelbrot): "Mandelbrot",
+	int(Buddhabrot): "Buddhabrot",
+	int(Domain):     "Domain",
 }
 
 // CalcPoint is the mapping between coordinate types.
@@ -35,7 +39,7 @@ type CalcPoint struct {
 }
 
 // ColorResults maps image plane coordinates to a color.
-type ColorResults map[plane.ImagePoint]color.NRGBA
+type ColorResults map[plane.ImagePoint]LinearColor
 
 // CalcParams contains all the parameters needed to generate an image.
 type CalcParams struct {
@@ -50,30 +54,65 @@ type CalcParams struct {
 	CalcArea         plane.PlaneView
 	RPoints, IPoints int
 
+	// Accumulating styles can calculate random points in the CalcArea until
+	// the Budget is spent or the noise drops to TargetNoise, instead of
+	// calculating RPoints x IPoints.
+	Budget      time.Duration
+	TargetNoise float64
+
 	Concurrency int
+	Subdivide   bool     // use Mariani-Silver subdivision for escape-time styles
+	Symmetry    Symmetry // zero detects symmetry for escape-time styles
+	Detail      bool     // record CalcDetail for escape-time styles
+	Mask        Mask     // restrict the results to a region of the image
+	Complex64   bool     // iterate in complex64 precision, with float32 parts
+
+	// Store also counts the orbit points of accumulating styles by their
+	// complex coordinates so they can be rasterized to other views.
+	Store *SampleStore
+
+	CH ChannelFunc
 
 	CF  ColorFunc
 	CFP ColorFuncParams
+
+	full *CalcParams // the params a Preview was made from
 }
 
 func (cs CalcStyle) String() string {
 	return CalcStyleName[int(cs)]
 }
 
+// Accumulates returns whether every point in an orbit is added to the
+// histogram, as opposed to escape-time styles which add to the starting point.
+func (cs CalcStyle) Accumulates() bool {
+	return cs == Attractor || cs == Buddhabrot
+}
+
 func (cp CalcPoint) String() string {
 	return fmt.Sprintf("{%v, %v}", cp.Z, cp.XY)
 }
 
 func (cp *CalcParams) String() string {
 	return fmt.Sprintf(
-		"CalcParams{\n%v\nStyle: %v\n%v\n%v\n%v\nc: %v\niterations: %v\nlimit: %v\n"+
+		"CalcParams{\n%v\nStyle: %v\n%v\n%v\n%v\n%v\nc: %v\niterations: %v\nlimit: %v\n"+
 			"calc area: %v\n"+
 			"real points: %v in (%v -> %v | %v)\nimag points: %v in (%vi -> %vi | %vi)\n"+
-			"concurrency: %d\n}",
-		cp.Plane, cp.Style, cp.ZF, cp.CF, cp.CFP, cp.C, cp.Iterations, cp.Limit, cp.CalcArea,
+			"budget: %v, target noise: %v\n"+
+			"concurrency: %d, subdivide: %v, symmetry: %v, detail: %v, mask: %v, complex64: %v\n}",
+		cp.Plane, cp.Style, cp.ZF, cp.CH, cp.CF, cp.CFP, cp.C, cp.Iterations, cp.Limit, cp.CalcArea,
 		cp.RPoints, real(cp.CalcArea.Min), real(cp.CalcArea.Max), cp.CalcArea.RealLen(),
 		cp.IPoints, imag(cp.CalcArea.Min), imag(cp.CalcArea.Max), cp.CalcArea.ImagLen(),
-		cp.Concurrency)
+		cp.Budget, cp.TargetNoise,
+		cp.Concurrency, cp.CanSubdivide(), cp.Symmetries(), cp.RecordsDetail(), cp.Mask,
+		cp.Complex64)
+}
+
+// RecordsDetail returns whether a CalcDetail is recorded for each point,
+// either because it was asked for or the ColorFunc needs it. The Domain style
+// always records it since its result is the final z.
+func (cp *CalcParams) RecordsDetail() bool {
+	return cp.Detail || cp.CF.Detail || cp.Style == Domain
 }
 
 // NewCalcParams returns a new CalcParams object based on the given one.
@@ -99,10 +138,23 @@ func NewCalcParams(cp CalcParams) *CalcParams {
 		RPoints:  cp.RPoints,
 		IPoints:  cp.IPoints,
 
+		Budget:      cp.Budget,
+		TargetNoise: cp.TargetNoise,
+
 		Concurrency: cp.Concurrency,
+		Subdivide:   cp.Subdivide,
+		Symmetry:    cp.Symmetry,
+		Detail:      cp.Detail,
+		Mask:        cp.Mask,
+		Complex64:   cp.Complex64,
+		Store:       cp.Store,
+
+		CH: cp.CH,
 
 		CF:  cp.CF,
 		CFP: cp.CFP,
+
+		full: cp.full,
 	}
 }
 
@@ -129,9 +181,55 @@ func (cp CalcParams) NewAllPoints(iterations int, cf ColorFunc, cfp ColorFuncPar
 		C:           cp.C,
 		Limit:       cp.Limit,
 		Concurrency: cp.Concurrency,
+		Subdivide:   cp.Subdivide,
+		Symmetry:    cp.Symmetry,
+		Detail:      cp.Detail,
+		Mask:        cp.Mask,
+		Complex64:   cp.Complex64,
+		Store:       cp.Store,
+		CH:          cp.CH,
 	}
 }
 
+// Preview returns params for a quick thumbnail of the same scene. The image
+// is scale times smaller, there are scale times fewer iterations, points and
+// budget, and orbits are iterated in complex64. The mask and sample store
+// are for the full image so they're left out.
+func (cp *CalcParams) Preview(scale int) *CalcParams {
+	full := cp.Promote()
+	if scale < 1 {
+		scale = 1
+	}
+
+	p := *full
+	p.Plane = plane.NewPlane(full.Plane.Origin(), full.Plane.Size(), max(full.Plane.ImageHeight()/scale, 1)).
+		WithDepth(full.Plane.Depth())
+	if full.Plane.IsInverted() {
+		p.Plane.WithInverted()
+	}
+	if full.Plane.IsTransparent() {
+		p.Plane.WithTransparent()
+	}
+	p.Iterations = max(full.Iterations/scale, 1)
+	p.RPoints = max(full.RPoints/scale, 1)
+	p.IPoints = max(full.IPoints/scale, 1)
+	p.Budget = full.Budget / time.Duration(scale)
+	p.Mask = nil
+	p.Store = nil
+	p.Complex64 = true
+	p.full = full
+	return &p
+}
+
+// Promote returns the full precision params a Preview was made from, or the
+// params themselves if they aren't a preview.
+func (cp *CalcParams) Promote() *CalcParams {
+	if cp.full != nil {
+		return cp.full
+	}
+	return cp
+}
+
 // MakePlaneProblemSet returns a problem set for an even distribution of points in the calc_area.
 func (cp *CalcParams) MakePlaneProblemSet() (problems []CalcPoint) {
 	if cp.RPoints <= 0 || cp.IPoints <= 0 {
@@ -158,12 +256,16 @@ func (cp *CalcParams) MakePlaneProblemSet() (problems []CalcPoint) {
 	return
 }
 
-// MakeImageProblemSet returns a problem set representing every point in the image plane.
+// MakeImageProblemSet returns a problem set representing every point in the
+// image plane, or only those in the Mask.
 func (cp *CalcParams) MakeImageProblemSet() (problems []CalcPoint) {
 	t_start := time.Now()
 	for x := 0; x < cp.Plane.ImageWidth(); x++ {
 		for y := 0; y < cp.Plane.ImageHeight(); y++ {
 			xy := plane.ImagePoint{X: x, Y: y}
+			if !cp.inMask(xy) {
+				continue
+			}
 			z := cp.Plane.ToComplexPoint(xy)
 			problems = append(problems, CalcPoint{Z: z, XY: xy})
 		}
@@ -178,69 +280,17 @@ func (cp *CalcParams) Calculate(problems []CalcPoint) (histogram CalcResults) {
 	calc_id := fmt.Sprintf("%p", problems)
 	showed_progress := make(map[int]bool)
 
-	img_width := cp.Plane.ImageWidth()
-	img_height := cp.Plane.ImageHeight()
-
-	// rz_min, rz_max := real(cp.plane.view.min), real(cp.plane.view.max)
-	// iz_min, iz_max := imag(cp.plane.view.min), imag(cp.plane.view.max)
-
 	var total_its, num_escaped, num_periodic uint
 	histogram = make(CalcResults)
-	f_zc := cp.ZF.F
 
 	for progress, pt := range problems {
-		var z, c complex128
-		if cp.Style == Mandelbrot {
-			z = complex(0, 0)
-			c = pt.Z
-		} else {
-			z = pt.Z
-			c = cp.C
+		its, escaped, periodic := cp.orbit(pt, histogram)
+		total_its += its
+		if escaped {
+			num_escaped++
 		}
-
-		rag := make(map[complex128]bool)
-		for its := 0; its < cp.Iterations; its++ {
-			total_its++
-
-			z = f_zc(z, c)
-			xy := cp.Plane.ToImagePoint(z)
-			// if real(z) < rz_min || real(z) > rz_max || imag(z) < iz_min || imag(z) > iz_max {
-			// 	continue
-			// }
-
-			// Escaped?
-			if cmplx.Abs(z) > cp.Limit {
-				if cp.Style == Attractor {
-					histogram.Add(xy, z, 1).Escaped = true
-				} else {
-					histogram.Add(pt.XY, pt.Z, 1).Escaped = true
-				}
-				num_escaped++
-				// fmt.Printf("Point %v escaped after %v iterations\n", z0, its)
-				break
-			}
-
-			// Periodic?
-			if rag[z] {
-				if cp.Style == Attractor {
-					histogram.Add(xy, z, 1).Periodic = true
-				} else {
-					histogram.Add(pt.XY, pt.Z, 1).Periodic = true
-				}
-				num_periodic++
-				// fmt.Printf("Point %v become periodic after %v iterations\n", z0, its)
-				break
-			}
-			rag[z] = true
-
-			if cp.Style == Attractor {
-				// Only add to histogram if pixel is in the image plane.
-				if xy.X >= 0 && xy.X <= img_width && xy.Y >= 0 && xy.Y <= img_height {
-					histogram.Add(xy, z, 1)
-				}
-			} else {
-				histogram.Add(pt.XY, pt.Z, 1)
-			}
+		if periodic {
+			num_periodic++
 		}
 
 		// Show progress.
@@ -263,24 +313,172 @@ func (cp *CalcParams) Calculate(problems []CalcPoint) (histogram CalcResults) {
 	return
 }
 
+// orbit iterates a single point, adding the results to the histogram.
+// Complex64 previews iterate it in float32.
+func (cp *CalcParams) orbit(pt CalcPoint, histogram CalcResults) (total_its uint, escaped, periodic bool) {
+	if cp.Complex64 {
+		return orbit(cp, cp.ZF.F32, pt, histogram)
+	}
+	return orbit(cp, cp.ZF.F64, pt, histogram)
+}
+
+// orbit iterates a single point in the precision of the formula. Points are
+// only converted to complex128 when they're added to the results.
+func orbit[F Float](cp *CalcParams, f Formula[F], pt CalcPoint, histogram CalcResults) (total_its uint, escaped, periodic bool) {
+	if cp.Style == Domain {
+		return domain(cp, f, pt, histogram), false, false
+	}
+
+	img_width := cp.Plane.ImageWidth()
+	img_height := cp.Plane.ImageHeight()
+	accumulates := cp.Style.Accumulates()
+
+	var x, y, cx, cy F
+	if cp.Style == Mandelbrot || cp.Style == Buddhabrot {
+		cx, cy = F(real(pt.Z)), F(imag(pt.Z))
+	} else {
+		x, y = F(real(pt.Z)), F(imag(pt.Z))
+		cx, cy = F(real(cp.C)), F(imag(cp.C))
+	}
+	// Points added to the histogram are remembered when they need channels
+	// since the channel can depend on how the orbit ends.
+	var added []*CalcResult
+	add := func(xy plane.ImagePoint, z complex128) *CalcResult {
+		r := histogram.Add(xy, z, 1)
+		if cp.CH.F != nil {
+			added = append(added, r)
+		}
+		return r
+	}
+
+	detail := !accumulates && cp.RecordsDetail()
+	min_mod, min_its, period := math.Inf(1), 0, 0
+
+	rag := make(map[[2]F]int)
+	for its := 0; its < cp.Iterations; its++ {
+		total_its++
+
+		x, y = f(x, y, cx, cy)
+		// The modulus is |z| as in complex128, so full precision orbits
+		// escape exactly where they always have.
+		mod := math.Hypot(float64(x), float64(y))
+		if detail && mod < min_mod {
+			min_mod, min_its = mod, its+1
+		}
+		// Escape-time styles only add to the point they started from.
+		var xy plane.ImagePoint
+		if accumulates {
+			xy = cp.Plane.ToImagePoint(toComplex(x, y))
+		}
+
+		// Escaped?
+		if mod > cp.Limit {
+			if accumulates {
+				add(xy, toComplex(x, y)).Escaped = true
+			} else {
+				add(pt.XY, pt.Z).Escaped = true
+			}
+			escaped = true
+			break
+		}
+
+		// Periodic?
+		if seen, ok := rag[[2]F{x, y}]; ok {
+			period = its - seen
+			if accumulates {
+				add(xy, toComplex(x, y)).Periodic = true
+			} else {
+				add(pt.XY, pt.Z).Periodic = true
+			}
+			periodic = true
+			break
+		}
+		rag[[2]F{x, y}] = its
+
+		if accumulates {
+			// Only add points in the mask, and to the histogram only if
+			// they're in the image plane.
+			if !cp.inMask(xy) {
+				continue
+			}
+			if cp.Store != nil {
+				cp.Store.Add(toComplex(x, y))
+			}
+			if xy.X >= 0 && xy.X <= img_width && xy.Y >= 0 && xy.Y <= img_height {
+				add(xy, toComplex(x, y))
+			}
+		} else {
+			add(pt.XY, pt.Z)
+		}
+	}
+
+	// With no iterations nothing was added to record detail on.
+	if detail && total_its > 0 {
+		histogram[pt.XY].Detail = &CalcDetail{
+			Its: int(total_its), ZFinal: toComplex(x, y), Period: period, MinMod: min_mod, MinIts: min_its,
+		}
+	}
+
+	if cp.CH.F != nil {
+		o := Orbit{Seed: pt.Z, Its: int(total_its), Escaped: escaped, Periodic: periodic}
+		for n, r := range added {
+			r.AddChannels(cp.CH.F(o, n), 1)
+		}
+	}
+	return
+}
+
+// domain applies the formula Iterations times (at least once) to a single
+// point, recording the result as the final z without checking for escape.
+func domain[F Float](cp *CalcParams, f Formula[F], pt CalcPoint, histogram CalcResults) (total_its uint) {
+	x, y := F(real(pt.Z)), F(imag(pt.Z))
+	cx, cy := F(real(cp.C)), F(imag(cp.C))
+	for total_its < uint(max(cp.Iterations, 1)) {
+		x, y = f(x, y, cx, cy)
+		total_its++
+	}
+	z := toComplex(x, y)
+	r := histogram.Add(pt.XY, pt.Z, total_its)
+	r.Detail = &CalcDetail{Its: int(total_its), ZFinal: z, MinMod: cmplx.Abs(z), MinIts: int(total_its)}
+	return
+}
+
 func TimestampMilli() string {
 	return time.Now().Format(time.StampMilli)
 }
 
+// MakeProblemSet returns the problem set appropriate for the calculation style.
+func (cp *CalcParams) MakeProblemSet() []CalcPoint {
+	if cp.Style.Accumulates() {
+		return cp.MakePlaneProblemSet()
+	}
+	return cp.MakeImageProblemSet()
+}
+
 // CalculateParallel breaks the problem set into chunks and runs conncurrent Calculate routines.
 func (cp *CalcParams) CalculateParallel() (histogram CalcResults) {
-	concurrency := cp.Concurrency
-	if cp.Concurrency == 0 {
-		concurrency = int(1.5 * float64(runtime.NumCPU()))
+	fmt.Printf("%v\n\n", cp)
+	if cp.CanSubdivide() {
+		return cp.CalculateSubdivided()
 	}
 
-	var problems []CalcPoint
-	if cp.Style == Attractor {
-		problems = cp.MakePlaneProblemSet()
-	} else {
-		problems = cp.MakeImageProblemSet()
+	problems, mirrors := cp.MakeSymmetricProblemSet(cp.MakeProblemSet(), cp.Symmetries())
+	histogram = cp.CalculateProblems(problems)
+	histogram.Mirror(mirrors)
+	return
+}
+
+// CalculateProblems runs concurrent Calculate routines over the given problem set.
+func (cp *CalcParams) CalculateProblems(problems []CalcPoint) (histogram CalcResults) {
+	histogram = make(CalcResults)
+	if len(problems) == 0 {
+		return
 	}
 
+	concurrency := cp.Concurrency
+	if cp.Concurrency == 0 {
+		concurrency = int(1.5 * float64(runtime.NumCPU()))
+	}
 	if len(problems) < concurrency {
 		concurrency = len(problems)
 	}
@@ -292,7 +490,6 @@ func (cp *CalcParams) CalculateParallel() (histogram CalcResults) {
 		problems[a], problems[b] = problems[b], problems[a]
 	})
 
-	fmt.Printf("%v\n\n", cp)
 	fmt.Printf("Logical CPUs: %v (will use %v concurrent routines)\n", runtime.NumCPU(), concurrency)
 	fmt.Printf("Orbits to calculate: %d (~%d per routine)\n", len(problems), chunk_size)
 
@@ -316,7 +513,6 @@ func (cp *CalcParams) CalculateParallel() (histogram CalcResults) {
 	}
 
 	// Collect results.
-	histogram = make(CalcResults)
 	chunks_received := 0
 	for r_chunk := range result_ch {
 		histogram.Merge(r_chunk)
@@ -329,15 +525,341 @@ func (cp *CalcParams) CalculateParallel() (histogram CalcResults) {
 	return
 }
 
-// ColorImage sets image colors based on the results from Calculate.
-func (cp *CalcParams) ColorImage() {
-	histogram := cp.CalculateParallel()
+// NumProblems returns the number of orbits in the problem set.
+func (cp *CalcParams) NumProblems() int {
+	if cp.Style.Accumulates() {
+		return cp.RPoints * cp.IPoints
+	}
+	return cp.Plane.ImageWidth() * cp.Plane.ImageHeight()
+}
+
+// Histogram calculates the results without coloring them.
+func (cp *CalcParams) Histogram() (histogram CalcResults, orbits int) {
+	if cp.Budgeted() {
+		fmt.Printf("%v\n\n", cp)
+		histogram, orbits = cp.CalculateBudgeted(nil)
+	} else {
+		histogram, orbits = cp.CalculateParallel(), cp.NumProblems()
+	}
 	histogram.PrintStats()
+	return
+}
+
+// ColorImage sets image colors based on the results from Calculate. It
+// returns the results and the number of orbits they represent.
+func (cp *CalcParams) ColorImage() (histogram CalcResults, orbits int) {
+	histogram, orbits = cp.Histogram()
 
 	t_start := time.Now()
-	colors := cp.CF.F(histogram, cp.CFP)
-	for pt, rgba := range colors {
-		cp.Plane.SetXYColor(pt.X, pt.Y, rgba)
-	}
+	cp.paint(histogram, 1)
 	fmt.Printf("Image processing took %dms\n", time.Since(t_start).Milliseconds())
+	return
+}
+
+// ColorImageProgressive sets image colors over a number of refining passes,
+// calling publish after each pass so the intermediate image can be inspected.
+//
+// Escape-time styles start by calculating every 2^(passes-1)th pixel and
+// halve the step each pass. Accumulating styles start with 1/4^(passes-1) of the seeds
+// and quadruple them each pass. Every pass reuses the results of the ones
+// before it, so the final image is the same as ColorImage would make.
+// Passes always calculate every pixel on their grid; Subdivide and Symmetry
+// are ignored.
+//
+// Budgeted calculations publish their results so far every BUDGET_PUBLISH
+// instead, so the number of passes is up to the budget.
+//
+// Like ColorImage, it returns the results and the number of orbits they represent.
+func (cp *CalcParams) ColorImageProgressive(passes int, publish func(pass int)) (histogram CalcResults, orbits int) {
+	if passes < 1 {
+		passes = 1
+	}
+	fmt.Printf("%v\n\n", cp)
+
+	if cp.Budgeted() {
+		pass := 0
+		histogram, orbits = cp.CalculateBudgeted(func(histogram CalcResults, orbits int) {
+			pass++
+			cp.paint(histogram, 1)
+			if publish != nil {
+				publish(pass)
+			}
+		})
+		histogram.PrintStats()
+		cp.paint(histogram, 1)
+		if publish != nil {
+			publish(pass + 1)
+		}
+		return
+	}
+
+	problems := cp.MakeProblemSet()
+	histogram, orbits = make(CalcResults), len(problems)
+	done := 0
+
+	// Shuffle up front so every prefix of the seeds is an even sample.
+	rand.Shuffle(len(problems), func(a, b int) {
+		problems[a], problems[b] = problems[b], problems[a]
+	})
+
+	for pass := 1; pass <= passes; pass++ {
+		t_start := time.Now()
+		remaining := passes - pass
+
+		var todo []CalcPoint
+		block := 1
+		if cp.Style.Accumulates() {
+			end := len(problems) >> (2 * remaining)
+			if pass == passes || end > len(problems) {
+				end = len(problems)
+			}
+			todo = problems[done:end]
+			done = end
+		} else {
+			block = 1 << remaining
+			prev_block := block * 2
+			for _, pt := range problems {
+				if pt.XY.X%block != 0 || pt.XY.Y%block != 0 {
+					continue
+				}
+				if pass > 1 && pt.XY.X%prev_block == 0 && pt.XY.Y%prev_block == 0 {
+					continue // calculated in a previous pass
+				}
+				todo = append(todo, pt)
+			}
+		}
+
+		fmt.Printf("[%v] 🔎 Pass %d/%d | %d orbits\n", TimestampMilli(), pass, passes, len(todo))
+		histogram.Merge(cp.CalculateProblems(todo))
+
+		cp.paint(histogram, block)
+		fmt.Printf("Pass %d/%d took %dms\n", pass, passes, time.Since(t_start).Milliseconds())
+
+		if publish != nil {
+			publish(pass)
+		}
+	}
+	histogram.PrintStats()
+	return
+}
+
+// paint colors the image using the results. Escape-time results are drawn as
+// block x block squares anchored at points on the block grid.
+func (cp *CalcParams) paint(histogram CalcResults, block int) {
+	paintColors(cp.Plane, cp.Colors(histogram), block, cp.CFP.Dither, cp.Mask)
+}
+
+// Colors returns the histogram colored by the ColorFunc, choosing the
+// exposure for automatic percentile ColorFuncs. Pipelines choose alpha
+// themselves when the colors are transparent; other ColorFuncs get alpha from
+// brightness.
+func (cp *CalcParams) Colors(histogram CalcResults) ColorResults {
+	cfp := cp.CFP
+	if cfp.Limit == 0 {
+		cfp.Limit = cp.Limit
+	}
+	cfp = cp.autoExposure(histogram, cfp)
+	colors := cp.CF.F(histogram, cfp)
+	if cfp.Transparent && cp.CF.Pipeline == nil {
+		for xy, c := range colors {
+			colors[xy] = c.AlphaFromBrightness()
+		}
+	}
+	return colors
+}
+
+// paintColors sets the colors in the plane's image, filling block x block
+// pixels from each point on the block grid when block is more than 1. Blocks
+// are clipped to the image and, if there is one, the mask. Colors are
+// dithered when they're quantized to 8 bits.
+func paintColors(p *plane.Plane, colors ColorResults, block int, dither Dither, mask Mask) {
+	if block > 1 {
+		bounds := p.Image().Bounds()
+		pixels := make(ColorResults)
+		for pt, c := range colors {
+			if pt.X%block != 0 || pt.Y%block != 0 {
+				continue
+			}
+			for x := pt.X; x < min(pt.X+block, bounds.Max.X); x++ {
+				for y := pt.Y; y < min(pt.Y+block, bounds.Max.Y); y++ {
+					xy := plane.ImagePoint{X: x, Y: y}
+					if mask == nil || mask.Contains(xy) {
+						pixels[xy] = c
+					}
+				}
+			}
+		}
+		colors = pixels
+	}
+
+	// Colors are encoded to sRGB only for output.
+	if p.Depth() == 16 {
+		for pt, c := range colors {
+			p.SetXYColor(pt.X, pt.Y, c.NRGBA64())
+		}
+		return
+	}
+	for pt, c := range dither.Quantize(colors) {
+		p.SetXYColor(pt.X, pt.Y, c)
+	}
+}
+
+// calcParamsJSON is the JSON representation of CalcParams. Functions are
+// stored by name. A ChannelFunc without a name is stored by its description
+// and can't be read back.
+type calcParamsJSON struct {
+	Plane *plane.Plane `json:"plane"`
+
+	Style      string     `json:"style"`
+	ZF         string     `json:"zf"`
+	C          [2]float64 `json:"c"`
+	Iterations int        `json:"iterations"`
+	Limit      float64    `json:"limit"`
+
+	CalcArea [4]float64 `json:"calc_area"`
+	RPoints  int        `json:"r_points"`
+	IPoints  int        `json:"i_points"`
+
+	Budget      string  `json:"budget,omitempty"`
+	TargetNoise float64 `json:"target_noise,omitempty"`
+
+	Subdivide bool     `json:"subdivide,omitempty"`
+	Symmetry  Symmetry `json:"symmetry,omitempty"`
+	Detail    bool     `json:"detail,omitempty"`
+	Complex64 bool     `json:"complex64,omitempty"`
+
+	// Full holds the params a preview was made from so it can be promoted.
+	Full *CalcParams `json:"full,omitempty"`
+
+	CH string `json:"ch,omitempty"`
+
+	CF       string          `json:"cf"`
+	Pipeline *Pipeline       `json:"pipeline,omitempty"` // for a ColorFunc which isn't one of the known ones
+	CFP      ColorFuncParams `json:"cfp"`
+}
+
+func (cp *CalcParams) MarshalJSON() ([]byte, error) {
+	v := calcParamsJSON{
+		Plane: cp.Plane,
+
+		Style:      cp.Style.String(),
+		ZF:         cp.ZF.Name,
+		C:          [2]float64{real(cp.C), imag(cp.C)},
+		Iterations: cp.Iterations,
+		Limit:      cp.Limit,
+
+		CalcArea: [4]float64{
+			real(cp.CalcArea.Min), imag(cp.CalcArea.Min), real(cp.CalcArea.Max), imag(cp.CalcArea.Max)},
+		RPoints: cp.RPoints,
+		IPoints: cp.IPoints,
+
+		TargetNoise: cp.TargetNoise,
+
+		Subdivide: cp.Subdivide,
+		Symmetry:  cp.Symmetry,
+		Detail:    cp.Detail,
+		Complex64: cp.Complex64,
+		Full:      cp.full,
+
+		CF:  cp.CF.Name,
+		CFP: cp.CFP,
+	}
+	if cp.Budget > 0 {
+		v.Budget = cp.Budget.String()
+	}
+	if cp.CH.Desc != "" {
+		v.CH = cp.CH.Name
+		if v.CH == "" {
+			v.CH = cp.CH.Desc
+		}
+	}
+	if known, ok := ColorFuncs[cp.CF.Name]; !ok || known.Pipeline != cp.CF.Pipeline {
+		v.Pipeline = cp.CF.Pipeline
+	}
+	return json.Marshal(v)
+}
+
+func (cp *CalcParams) UnmarshalJSON(data []byte) error {
+	var v calcParamsJSON
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	if v.Plane == nil {
+		return fmt.Errorf("missing plane")
+	}
+
+	style := -1
+	for n, name := range CalcStyleName {
+		if name == v.Style {
+			style = n
+		}
+	}
+	if style < 0 {
+		return fmt.Errorf("unknown style: %q", v.Style)
+	}
+
+	zf, ok := ZFuncs[v.ZF]
+	if !ok {
+		return fmt.Errorf("unknown zf: %q", v.ZF)
+	}
+
+	var cf ColorFunc
+	switch {
+	case v.Pipeline != nil:
+		name := v.CF
+		if name == "" {
+			name = "pipeline"
+		}
+		cf = v.Pipeline.ColorFunc(name, v.Pipeline.String())
+	case v.CF != "":
+		if cf, ok = ColorFuncs[v.CF]; !ok {
+			return fmt.Errorf("unknown cf: %q", v.CF)
+		}
+	}
+
+	var ch ChannelFunc
+	if v.CH != "" {
+		if ch, err = LookupChannelFunc(v.CH); err != nil {
+			return err
+		}
+	}
+
+	var budget time.Duration
+	if v.Budget != "" {
+		if budget, err = time.ParseDuration(v.Budget); err != nil {
+			return err
+		}
+	}
+
+	*cp = *NewCalcParams(CalcParams{
+		Plane: v.Plane,
+
+		Style:      CalcStyle(style),
+		ZF:         zf,
+		C:          complex(v.C[0], v.C[1]),
+		Iterations: v.Iterations,
+		Limit:      v.Limit,
+
+		CalcArea: plane.PlaneView{
+			Min: complex(v.CalcArea[0], v.CalcArea[1]), Max: complex(v.CalcArea[2], v.CalcArea[3])},
+		RPoints: v.RPoints,
+		IPoints: v.IPoints,
+
+		Budget:      budget,
+		TargetNoise: v.TargetNoise,
+
+		Subdivide: v.Subdivide,
+		Symmetry:  v.Symmetry,
+		Detail:    v.Detail,
+		Complex64: v.Complex64,
+
+		CH: ch,
+
+		CF:  cf,
+		CFP: v.CFP,
+
+		full: v.Full,
+	})
+	return nil
 }
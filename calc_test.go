@@ -1,6 +1,10 @@
 package main
 
 import (
+	"image"
+	"math"
+	"math/cmplx"
+	"slices"
 	"sort"
 	"testing"
 
@@ -82,3 +86,308 @@ func TestMakePlaneProblemSetSinglePoint(t *testing.T) {
 		t.Error(result[0], expect[0])
 	}
 }
+
+func TestColorImageProgressiveMatchesColorImage(t *testing.T) {
+	for _, dither := range []Dither{NoDither, OrderedDither, FloydSteinberg} {
+		params := CalcParams{
+			Plane:      plane.NewPlane(complex(0, 0), complex(4, 4), 24),
+			Style:      Julia,
+			ZF:         zf_mandelbrot,
+			C:          complex(0.285, 0.01),
+			Iterations: 64,
+			Limit:      2,
+			CF:         cf_escaped_clip_value,
+			CFP:        ColorFuncParams{Clip: 32, Dither: dither},
+		}
+		params.ColorImage()
+		expect := params.Plane.Image().(*image.NRGBA)
+
+		progressive := params
+		progressive.Plane = plane.NewPlane(complex(0, 0), complex(4, 4), 24)
+		published := 0
+		progressive.ColorImageProgressive(3, func(pass int) { published++ })
+		result := progressive.Plane.Image().(*image.NRGBA)
+
+		if published != 3 {
+			t.Errorf("%v: Expected 3 published passes, got %d", dither, published)
+		}
+		for i := range expect.Pix {
+			if result.Pix[i] != expect.Pix[i] {
+				t.Fatalf("%v: Images differ at byte %d: %v != %v", dither, i, result.Pix[i], expect.Pix[i])
+			}
+		}
+	}
+}
+
+func TestCalculateSubdividedMatchesCalculate(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(-0.5, 0), complex(3, 3), 96),
+		Style:      Mandelbrot,
+		ZF:         zf_mandelbrot,
+		Iterations: 64,
+		Limit:      2,
+		Subdivide:  true,
+		CF:         cf_escaped_clip_value,
+	}
+	if !params.CanSubdivide() {
+		t.Fatalf("Expected params to be subdividable")
+	}
+	result := params.CalculateSubdivided()
+	expect := params.CalculateProblems(params.MakeImageProblemSet())
+
+	if len(result) != len(expect) {
+		t.Fatalf("len(result) = %d; want %d", len(result), len(expect))
+	}
+	for xy, e := range expect {
+		r, ok := result[xy]
+		if !ok {
+			t.Fatalf("Missing result for %v", xy)
+		}
+		if !r.SameOutcome(e) {
+			t.Errorf("Result at %v differs: %v != %v", xy, *r, *e)
+		}
+	}
+}
+
+func TestCanSubdivideExactColorFunc(t *testing.T) {
+	params := CalcParams{Style: Julia, Subdivide: true, CF: cf_luma_clip_value}
+	if params.CanSubdivide() {
+		t.Errorf("Expected an exact ColorFunc to prevent subdivision")
+	}
+}
+
+func TestCalculateDetail(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(8, 8), 8),
+		Style:      Julia,
+		ZF:         zf_mandelbrot,
+		Iterations: 16,
+		Limit:      2,
+		Detail:     true,
+	}
+	testCases := []struct {
+		name   string
+		z      complex128
+		expect CalcDetail
+	}{
+		{"escapes", complex(3, 0), CalcDetail{Its: 1, ZFinal: complex(9, 0), MinMod: 9, MinIts: 1}},
+		{"periodic", complex(0, 0), CalcDetail{Its: 2, ZFinal: complex(0, 0), Period: 1, MinMod: 0, MinIts: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pt := CalcPoint{Z: tc.z, XY: plane.ImagePoint{X: 1, Y: 1}}
+			result := params.Calculate([]CalcPoint{pt})[pt.XY].Detail
+			if result == nil {
+				t.Fatalf("Expected detail to be recorded")
+			}
+			if *result != tc.expect {
+				t.Errorf("Expected %+v, got %+v", tc.expect, *result)
+			}
+		})
+	}
+}
+
+func TestCalculateDetailNoIterations(t *testing.T) {
+	params := CalcParams{
+		Plane:  plane.NewPlane(complex(0, 0), complex(8, 8), 8),
+		Style:  Julia,
+		ZF:     zf_mandelbrot,
+		Limit:  2,
+		Detail: true,
+	}
+	pt := CalcPoint{Z: complex(3, 0), XY: plane.ImagePoint{X: 1, Y: 1}}
+	if result := params.Calculate([]CalcPoint{pt}); len(result) != 0 {
+		t.Errorf("Expected no results without iterations, got %v", result)
+	}
+}
+
+func TestCalculateNoDetail(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(8, 8), 8),
+		Style:      Julia,
+		ZF:         zf_mandelbrot,
+		Iterations: 16,
+		Limit:      2,
+	}
+	pt := CalcPoint{Z: complex(3, 0), XY: plane.ImagePoint{X: 1, Y: 1}}
+	if result := params.Calculate([]CalcPoint{pt})[pt.XY].Detail; result != nil {
+		t.Errorf("Expected no detail, got %+v", *result)
+	}
+}
+
+func TestCalculateDomain(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(8, 8), 8),
+		Style:      Domain,
+		ZF:         zf_mandelbrot,
+		C:          complex(1, 0),
+		Iterations: 2,
+		Limit:      2,
+	}
+	// Points far past the limit don't escape, f is applied twice.
+	pt := CalcPoint{Z: complex(3, 0), XY: plane.ImagePoint{X: 1, Y: 1}}
+	result := params.Calculate([]CalcPoint{pt})[pt.XY]
+	if result.Escaped || result.Detail == nil {
+		t.Fatalf("Expected an unescaped result with detail, got %+v", result)
+	}
+	if expect := complex(101, 0); result.Detail.ZFinal != expect {
+		t.Errorf("Expected f(f(3)) = %v, got %v", expect, result.Detail.ZFinal)
+	}
+
+	histogram := params.CalculateParallel()
+	if expect := params.Plane.ImageWidth() * params.Plane.ImageHeight(); len(histogram) != expect {
+		t.Errorf("Expected a result for all %d pixels, got %d", expect, len(histogram))
+	}
+}
+
+func TestColorsTransparent(t *testing.T) {
+	params := CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(4, 4), 4).WithTransparent(),
+		CF:    cf_escaped_clip_value,
+		CFP:   ColorFuncParams{Clip: 10, Gamma: 1, Transparent: true},
+	}
+	histogram := make(CalcResults)
+	histogram.Add(plane.ImagePoint{X: 0, Y: 0}, 0, 5)                 // interior
+	histogram.Add(plane.ImagePoint{X: 1, Y: 0}, 0, 5).Escaped = true  // half the clip
+	histogram.Add(plane.ImagePoint{X: 2, Y: 0}, 0, 20).Escaped = true // clipped
+	r := histogram.Add(plane.ImagePoint{X: 3, Y: 0}, 0, 0)            // interior with a cycle
+	r.Detail = &CalcDetail{Period: 2}
+
+	alphas := func() []uint8 {
+		params.paint(histogram, 1)
+		img := params.Plane.Image().(*image.NRGBA)
+		var alphas []uint8
+		for x := 0; x < 4; x++ {
+			alphas = append(alphas, img.NRGBAAt(x, 0).A)
+		}
+		return alphas
+	}
+	testCases := []struct {
+		cf     ColorFunc
+		expect []uint8
+	}{
+		// Alpha is the normalized value, and points outside the set are
+		// see-through.
+		{cf_escaped_clip_value, []uint8{0, 0x80, 0xff, 0}},
+		// Even where the palette doesn't start at black.
+		{cf_palette_clip_value, []uint8{0x80, 0x80, 0xff, 0}},
+		// Hues aren't ranged, so points in the set are opaque.
+		{cf_interior_period, []uint8{0, 0, 0, 0xff}},
+	}
+	params.CFP.Palette = pal_rainbow
+	for _, tc := range testCases {
+		params.CF = tc.cf
+		if result := alphas(); !slices.Equal(result, tc.expect) {
+			t.Errorf("%v has alphas %#x; want %#x", tc.cf, result, tc.expect)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	full := NewCalcParams(CalcParams{
+		Plane:      plane.NewPlane(complex(-0.5, 0), complex(3, 3), 96),
+		Style:      Mandelbrot,
+		ZF:         zf_mandelbrot,
+		Iterations: 64,
+		Limit:      2,
+		CF:         cf_escaped_clip_value,
+	})
+	preview := full.Preview(4)
+
+	if w, h := preview.Plane.ImageWidth(), preview.Plane.ImageHeight(); w != 24 || h != 24 {
+		t.Errorf("Expected a 24x24 preview, got %dx%d", w, h)
+	}
+	if preview.Plane.View() != full.Plane.View() {
+		t.Errorf("Expected view %v, got %v", full.Plane.View(), preview.Plane.View())
+	}
+	if preview.Iterations != 16 || !preview.Complex64 {
+		t.Errorf("Expected 16 complex64 iterations, got %d (complex64 %v)", preview.Iterations, preview.Complex64)
+	}
+	if preview.Promote() != full || full.Promote() != full {
+		t.Errorf("Expected the preview to promote to the full params")
+	}
+	if again := preview.Preview(2); again.Promote() != full {
+		t.Errorf("Expected a preview of a preview to promote to the full params")
+	}
+}
+
+func TestCalculateComplex64(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(-0.5, 0), complex(3, 3), 48),
+		Style:      Mandelbrot,
+		ZF:         zf_mandelbrot,
+		Iterations: 32,
+		Limit:      2,
+	}
+	problems := params.MakeImageProblemSet()
+	expect := params.Calculate(problems)
+	params.Complex64 = true
+	result := params.Calculate(problems)
+
+	differ := 0
+	for xy, e := range expect {
+		if r := result[xy]; r.Escaped != e.Escaped {
+			differ++
+		}
+	}
+	if differ > len(expect)/100 {
+		t.Errorf("Expected complex64 to rarely change whether points escape, %d of %d differ", differ, len(expect))
+	}
+}
+
+func TestZFuncPrecisionsAgree(t *testing.T) {
+	z, c := complex(0.3, -0.4), complex(-0.2, 0.1)
+	for name, zf := range ZFuncs {
+		if zf.F32 == nil {
+			t.Errorf("%s has no float32 F32", name)
+			continue
+		}
+		expect := zf.F(z, c)
+		result := toComplex(zf.F32(float32(real(z)), float32(imag(z)), float32(real(c)), float32(imag(c))))
+		if cmplx.Abs(result-expect) > 1e-6 {
+			t.Errorf("%s: F32 = %v; F = %v", name, result, expect)
+		}
+	}
+}
+
+func TestZFuncFormulas(t *testing.T) {
+	z, c := complex(0.3, -0.4), complex(-0.2, 0.1)
+	x, y := real(z), imag(z)
+	testCases := []struct {
+		zf     ZFunc
+		expect complex128
+	}{
+		{zf_burning_ship, cmplx.Pow(complex(math.Abs(x), math.Abs(y)), 2) + c},
+		{zf_klein, cmplx.Pow(z, 2) + complex(-y, math.Abs(x)) + c},
+		{zf_klein2, cmplx.Pow(z, 2) + complex(math.Abs(y), x) + c},
+		{zf_mandelbrot, cmplx.Pow(z, 2) + c},
+	}
+	for _, tc := range testCases {
+		// Full precision is bit for bit what it always was.
+		if result := tc.zf.F(z, c); result != tc.expect {
+			t.Errorf("%s: F = %v; want %v", tc.zf.Name, result, tc.expect)
+		}
+		if x, y := tc.zf.F64(real(z), imag(z), real(c), imag(c)); complex(x, y) != tc.expect {
+			t.Errorf("%s: F64 = %v; want %v", tc.zf.Name, complex(x, y), tc.expect)
+		}
+	}
+}
+
+func TestPaint16Bit(t *testing.T) {
+	params := CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(4, 4), 4).WithDepth(16),
+		CF:    cf_luma_clip_value,
+		CFP:   ColorFuncParams{Clip: 1000, Gamma: 1},
+	}
+	histogram := make(CalcResults)
+	histogram.Add(plane.ImagePoint{X: 0, Y: 0}, 0, 1)
+	histogram.Add(plane.ImagePoint{X: 1, Y: 0}, 0, 2)
+	params.paint(histogram, 1)
+
+	// Values too close for 8 bits are still told apart.
+	img := params.Plane.Image().(*image.NRGBA64)
+	a, b := img.NRGBA64At(0, 0), img.NRGBA64At(1, 0)
+	if a.R == 0 || a.R >= b.R || a.R>>8 != b.R>>8 {
+		t.Errorf("Expected distinct 16 bit values in one 8 bit step, got %v and %v", a, b)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math"
+	"math/cmplx"
 	"sort"
 
 	"github.com/brainsik/bae/plane"
@@ -14,6 +15,28 @@ type CalcResult struct {
 	Val uint
 
 	Escaped, Periodic bool
+
+	Chans  *Channels   // only when calculated with a ChannelFunc
+	Detail *CalcDetail // only when CalcParams records detail
+}
+
+// CalcDetail are the extra results recorded for an escape-time point.
+type CalcDetail struct {
+	Its    int        // iterations until the orbit escaped, became periodic or ran out
+	ZFinal complex128 // last z in the orbit
+	Period int        // length of the cycle the orbit fell into, 0 if none was found
+	MinMod float64    // smallest |z| in the orbit
+	MinIts int        // iteration where the smallest |z| was reached
+}
+
+// SmoothIts returns a continuous escape iteration count for an orbit of a
+// degree 2 function which escaped past limit.
+func (cd *CalcDetail) SmoothIts(limit float64) float64 {
+	mod := cmplx.Abs(cd.ZFinal)
+	if mod <= limit || limit <= 1 {
+		return float64(cd.Its)
+	}
+	return float64(cd.Its) + 1 - math.Log2(math.Log(mod)/math.Log(limit))
 }
 
 // CalcResults maps an ImagePoint to the corresponding CalcResult for that point.
@@ -34,13 +57,57 @@ func (cr *CalcResult) Add(n uint) {
 func (cr CalcResults) Add(xy plane.ImagePoint, z complex128, val uint) *CalcResult {
 	cr_xy, ok := cr[xy]
 	if !ok {
-		cr[xy] = &CalcResult{z, val, false, false}
+		cr[xy] = &CalcResult{Z: z, Val: val}
 	} else {
 		cr_xy.Add(val)
 	}
 	return cr[xy]
 }
 
+// AddChannel adds n to the given channel. Channels out of range are ignored.
+func (cr *CalcResult) AddChannel(ch int, n uint) {
+	if ch < 0 || ch >= NUM_CHANNELS {
+		return
+	}
+	if cr.Chans == nil {
+		cr.Chans = new(Channels)
+	}
+	cr.Chans[ch] += n
+}
+
+// AddChannels adds n to each channel in the set.
+func (cr *CalcResult) AddChannels(cs ChannelSet, n uint) {
+	for ch := 0; ch < NUM_CHANNELS; ch++ {
+		if cs.Has(ch) {
+			cr.AddChannel(ch, n)
+		}
+	}
+}
+
+// Copy returns a copy of the result for the point z.
+func (cr *CalcResult) Copy(z complex128) *CalcResult {
+	dst := *cr
+	dst.Z = z
+	if cr.Chans != nil {
+		chans := *cr.Chans
+		dst.Chans = &chans
+	}
+	if cr.Detail != nil {
+		detail := *cr.Detail
+		dst.Detail = &detail
+	}
+	return &dst
+}
+
+// SameOutcome returns whether two escape-time results would be colored the
+// same: both escaped after the same number of iterations or both did not escape.
+func (cr *CalcResult) SameOutcome(other *CalcResult) bool {
+	if cr.Escaped != other.Escaped {
+		return false
+	}
+	return !cr.Escaped || cr.Val == other.Val
+}
+
 // Merge makes a union with src by adding vals and setting booleans to the OR'd value.
 func (cr CalcResults) Merge(src CalcResults) {
 	for k, v := range src {
@@ -51,6 +118,27 @@ func (cr CalcResults) Merge(src CalcResults) {
 			dst.Val += v.Val
 			dst.Escaped = dst.Escaped || v.Escaped
 			dst.Periodic = dst.Periodic || v.Periodic
+			if v.Chans != nil {
+				for ch, n := range v.Chans {
+					dst.AddChannel(ch, n)
+				}
+			}
+			if dst.Detail == nil {
+				dst.Detail = v.Detail
+			}
+		}
+	}
+}
+
+// Mirror copies the result for each calculated point to its mirror images.
+func (cr CalcResults) Mirror(mirrors map[plane.ImagePoint][]CalcPoint) {
+	for xy, pts := range mirrors {
+		src, ok := cr[xy]
+		if !ok {
+			continue
+		}
+		for _, pt := range pts {
+			cr[pt.XY] = src.Copy(pt.Z)
 		}
 	}
 }
@@ -88,6 +176,20 @@ func (cr CalcResults) MaxEscaped() float64 {
 	return float64(max)
 }
 
+// MaxChannel returns the highest value in the given channel.
+func (cr CalcResults) MaxChannel(ch int) float64 {
+	if len(cr) <= 0 {
+		return math.NaN()
+	}
+	var max uint
+	for _, v := range cr {
+		if v.Chans != nil && v.Chans[ch] > max {
+			max = v.Chans[ch]
+		}
+	}
+	return float64(max)
+}
+
 // Min returns the lowest val.
 func (cr CalcResults) Min() float64 {
 	if len(cr) <= 0 {
@@ -142,6 +244,37 @@ func (cr CalcResults) Median() float64 {
 	return float64(vals[len(vals)/2])
 }
 
+// Percentiles returns the vals of the points in the set below which each
+// percent p of them fall, interpolating between the ranks.
+func (cr CalcResults) Percentiles(points PointSet, ps ...float64) []float64 {
+	var vals []float64
+	for _, v := range cr {
+		if points.Has(v) {
+			vals = append(vals, float64(v.Val))
+		}
+	}
+	return percentiles(vals, ps...)
+}
+
+// percentiles returns the values below which each percent p of the values
+// fall, interpolating between the ranks. It sorts the values.
+func percentiles(vals []float64, ps ...float64) []float64 {
+	sort.Float64s(vals)
+	result := make([]float64, len(ps))
+	for n, p := range ps {
+		if len(vals) == 0 {
+			result[n] = math.NaN()
+			continue
+		}
+		rank := math.Max(0, math.Min(p/100, 1)) * float64(len(vals)-1)
+		below := int(rank)
+		above := min(below+1, len(vals)-1)
+		f := rank - float64(below)
+		result[n] = (1-f)*vals[below] + f*vals[above]
+	}
+	return result
+}
+
 // PrintStats outputs a variety of stats about what's in the CalcResults.
 func (cr CalcResults) PrintStats() {
 	var periodic, escaped uint
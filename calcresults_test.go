@@ -10,19 +10,19 @@ import (
 func TestCalcResultsMerge(t *testing.T) {
 	dst := CalcResults{
 		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{},
-		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{complex(-1, 1), 1, true, false},
+		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{Z: complex(-1, 1), Val: 1, Escaped: true, Periodic: false},
 	}
 	src := CalcResults{
-		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{complex(-2, 2), 2, false, true},
-		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{complex(-3, 3), 3, false, true},
+		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{Z: complex(-2, 2), Val: 2, Escaped: false, Periodic: true},
+		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{Z: complex(-3, 3), Val: 3, Escaped: false, Periodic: true},
 	}
 
 	dst.Merge(src)
 	result := dst
 
 	expect := CalcResults{
-		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{0, 2, false, true},
-		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{complex(-1, 1), 4, true, true},
+		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{Z: 0, Val: 2, Escaped: false, Periodic: true},
+		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{Z: complex(-1, 1), Val: 4, Escaped: true, Periodic: true},
 	}
 
 	for k := range result {
@@ -65,8 +65,8 @@ func TestCalcResultsAdd(t *testing.T) {
 
 func TestCalcResultsMaxEscaped(t *testing.T) {
 	crs := CalcResults{
-		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{complex(0, 0), 10, true, false},
-		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{complex(1, 1), 20, false, false},
+		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{Z: complex(0, 0), Val: 10, Escaped: true, Periodic: false},
+		plane.ImagePoint{X: 1, Y: 1}: &CalcResult{Z: complex(1, 1), Val: 20, Escaped: false, Periodic: false},
 	}
 	result := crs.MaxEscaped()
 	expect := 10.0
@@ -106,3 +106,42 @@ func TestCalcResultsEmptyNaN(t *testing.T) {
 		})
 	}
 }
+
+func TestCalcResultsMergeChannels(t *testing.T) {
+	pt := plane.ImagePoint{X: 0, Y: 0}
+	dst := make(CalcResults)
+	dst.Add(pt, 0, 1).AddChannel(0, 1)
+	src := make(CalcResults)
+	src.Add(pt, 0, 2).AddChannel(0, 2)
+	src[pt].AddChannel(2, 5)
+
+	dst.Merge(src)
+
+	expect := Channels{3, 0, 5}
+	if *dst[pt].Chans != expect {
+		t.Error(*dst[pt].Chans, expect)
+	}
+	if dst.MaxChannel(2) != 5 {
+		t.Error(dst.MaxChannel(2), 5)
+	}
+}
+
+func TestCalcDetailSmoothIts(t *testing.T) {
+	testCases := []struct {
+		name   string
+		detail CalcDetail
+		expect float64
+	}{
+		{"at-limit", CalcDetail{Its: 5, ZFinal: complex(2, 0)}, 5},
+		{"just-past-limit", CalcDetail{Its: 5, ZFinal: complex(2.0000001, 0)}, 6},
+		{"limit-squared", CalcDetail{Its: 5, ZFinal: complex(4, 0)}, 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.detail.SmoothIts(2)
+			if math.Abs(result-tc.expect) > 1e-6 {
+				t.Errorf("Expected %v, got %v", tc.expect, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// NUM_CHANNELS is the number of channels in a CalcResult. One each for red, green and blue.
+const NUM_CHANNELS = 3
+
+// Channels holds per-channel histogram values.
+type Channels [NUM_CHANNELS]uint
+
+// Orbit describes how the orbit of a point ended.
+type Orbit struct {
+	Seed              complex128
+	Its               int
+	Escaped, Periodic bool
+}
+
+// ChannelSet is a set of channels, one bit for each.
+type ChannelSet uint
+
+// Chans returns the set of the channels.
+func Chans(chs ...int) ChannelSet {
+	var cs ChannelSet
+	for _, ch := range chs {
+		cs |= 1 << ch
+	}
+	return cs
+}
+
+// Has returns whether the channel is in the set.
+func (cs ChannelSet) Has(ch int) bool {
+	return cs&(1<<ch) != 0
+}
+
+// ChannelFunc decides which channels each point of an orbit is accumulated
+// into. F is called with the finished orbit and the index of the point in
+// the orbit, and returns the channels, none to leave the point out.
+type ChannelFunc struct {
+	Name string
+	Desc string
+	F    func(o Orbit, n int) ChannelSet
+}
+
+func (chf ChannelFunc) String() string {
+	if chf.F == nil {
+		return "ChannelFunc: none"
+	}
+	return fmt.Sprintf("ChannelFunc: %s", chf.Desc)
+}
+
+// Named returns the ChannelFunc with a name, so it can be stored in scenes
+// and archives when it's one of the ChannelFuncs.
+func (chf ChannelFunc) Named(name string) ChannelFunc {
+	chf.Name = name
+	return chf
+}
+
+// ChannelByEscapeIts returns a ChannelFunc that adds escaped orbits to every
+// channel whose limit they escaped within, so each channel is a Buddhabrot of
+// the orbits up to its limit. With 5000, 500, 50, as in the classic
+// Nebulabrot, an orbit escaping in 10 iterations adds to all three channels
+// and one escaping in 600 only to channel 0. Orbits which don't escape within
+// any limit are left out.
+func ChannelByEscapeIts(limits ...int) ChannelFunc {
+	return ChannelFunc{
+		Desc: fmt.Sprintf("Escaped orbits within iterations %v", limits),
+		F: func(o Orbit, n int) ChannelSet {
+			var cs ChannelSet
+			if !o.Escaped {
+				return cs
+			}
+			for ch, limit := range limits {
+				if o.Its <= limit {
+					cs |= Chans(ch)
+				}
+			}
+			return cs
+		},
+	}
+}
+
+// ChannelBySeedRegion returns a ChannelFunc that bins orbits by which view
+// their starting point is in. Orbits starting outside every view are left out.
+func ChannelBySeedRegion(views ...plane.PlaneView) ChannelFunc {
+	return ChannelFunc{
+		Desc: fmt.Sprintf("Orbits by seed region %v", views),
+		F: func(o Orbit, n int) ChannelSet {
+			for ch, v := range views {
+				if real(o.Seed) >= real(v.Min) && real(o.Seed) <= real(v.Max) &&
+					imag(o.Seed) >= imag(v.Min) && imag(o.Seed) <= imag(v.Max) {
+					return Chans(ch)
+				}
+			}
+			return 0
+		},
+	}
+}
+
+var ch_nebulabrot = ChannelByEscapeIts(5000, 500, 50).Named("nebulabrot") //nolint:unused
+
+var ch_orbit_phase = ChannelFunc{ //nolint:unused
+	Name: "orbit_phase",
+	Desc: `Orbit points cycle through the channels`,
+	F: func(o Orbit, n int) ChannelSet {
+		return Chans(n % NUM_CHANNELS)
+	},
+}
+
+// ChannelFuncs are the known ChannelFuncs by name.
+var ChannelFuncs = map[string]ChannelFunc{
+	ch_nebulabrot.Name:  ch_nebulabrot,
+	ch_orbit_phase.Name: ch_orbit_phase,
+}
+
+// LookupChannelFunc returns the known ChannelFunc with the name, or with the
+// description as written by archives from before ChannelFuncs had names.
+func LookupChannelFunc(name string) (ChannelFunc, error) {
+	if chf, ok := ChannelFuncs[name]; ok {
+		return chf, nil
+	}
+	for _, chf := range ChannelFuncs {
+		if chf.Desc == name {
+			return chf, nil
+		}
+	}
+	return ChannelFunc{}, fmt.Errorf("unknown ch: %q", name)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestChannelByEscapeIts(t *testing.T) {
+	chf := ChannelByEscapeIts(5000, 500, 50)
+	testCases := []struct {
+		orbit  Orbit
+		expect ChannelSet
+	}{
+		{Orbit{Its: 10, Escaped: true}, Chans(0, 1, 2)},
+		{Orbit{Its: 50, Escaped: true}, Chans(0, 1, 2)},
+		{Orbit{Its: 51, Escaped: true}, Chans(0, 1)},
+		{Orbit{Its: 4999, Escaped: true}, Chans(0)},
+		{Orbit{Its: 5001, Escaped: true}, Chans()},
+		{Orbit{Its: 10, Escaped: false}, Chans()},
+	}
+	for _, tc := range testCases {
+		if result := chf.F(tc.orbit, 0); result != tc.expect {
+			t.Errorf("%+v: expected channels %b, got %b", tc.orbit, tc.expect, result)
+		}
+	}
+}
+
+func TestNebulabrotShortOrbit(t *testing.T) {
+	r := &CalcResult{}
+	r.AddChannels(ch_nebulabrot.F(Orbit{Its: 30, Escaped: true}, 0), 1)
+	if r.Chans == nil || *r.Chans != (Channels{1, 1, 1}) {
+		t.Errorf("Expected a 30 iteration orbit in every channel, got %v", r.Chans)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// LinearColor is a color in linear light with sRGB primaries and straight
+// alpha, each from 0 to 1. ColorFuncs return LinearColors so colors are
+// mixed the way light mixes, and they're only encoded to sRGB for output.
+type LinearColor struct {
+	R, G, B, A float64
+}
+
+var (
+	Black = LinearColor{0, 0, 0, 1}
+	White = LinearColor{1, 1, 1, 1}
+)
+
+// LinearOf decodes an 8-bit sRGB color.
+func LinearOf(c color.NRGBA) LinearColor {
+	return LinearColor{SRGBToLinear(c.R), SRGBToLinear(c.G), SRGBToLinear(c.B), float64(c.A) / 0xff}
+}
+
+// DisplayColor decodes an opaque color whose components are display
+// brightnesses from 0 to 1, as made by gamma correcting densities.
+func DisplayColor(r, g, b float64) LinearColor {
+	return LinearColor{DecodeSRGB(r), DecodeSRGB(g), DecodeSRGB(b), 1}
+}
+
+// DisplayGray decodes an opaque gray of the given display brightness.
+func DisplayGray(v float64) LinearColor {
+	return DisplayColor(v, v, v)
+}
+
+// NRGBA encodes the color as 8-bit sRGB.
+func (c LinearColor) NRGBA() color.NRGBA {
+	return color.NRGBA{LinearToSRGB(c.R), LinearToSRGB(c.G), LinearToSRGB(c.B), uint8(math.Round(0xff * clamp01(c.A)))}
+}
+
+// NRGBA64 encodes the color as 16-bit sRGB.
+func (c LinearColor) NRGBA64() color.NRGBA64 {
+	enc := func(v float64) uint16 { return uint16(math.Round(0xffff * v)) }
+	return color.NRGBA64{enc(EncodeSRGB(c.R)), enc(EncodeSRGB(c.G)), enc(EncodeSRGB(c.B)), enc(clamp01(c.A))}
+}
+
+// AlphaFromBrightness returns the color made as transparent as it is dark,
+// with black fully transparent. Composited over black by image editors,
+// which blend sRGB encoded colors, it looks the same as the original.
+func (c LinearColor) AlphaFromBrightness() LinearColor {
+	r, g, b := EncodeSRGB(c.R), EncodeSRGB(c.G), EncodeSRGB(c.B)
+	a := math.Max(r, math.Max(g, b))
+	if a == 0 {
+		return LinearColor{}
+	}
+	return LinearColor{DecodeSRGB(r / a), DecodeSRGB(g / a), DecodeSRGB(b / a), a * clamp01(c.A)}
+}
+
+// Mix returns the color f of the way from c to d.
+func (c LinearColor) Mix(d LinearColor, f float64) LinearColor {
+	lerp := func(x, y float64) float64 { return x + f*(y-x) }
+	return LinearColor{lerp(c.R, d.R), lerp(c.G, d.G), lerp(c.B, d.B), lerp(c.A, d.A)}
+}
+
+// Over returns the color composited over bg.
+func (c LinearColor) Over(bg LinearColor) LinearColor {
+	a := c.A + bg.A*(1-c.A)
+	if a == 0 {
+		return LinearColor{}
+	}
+	over := func(x, y float64) float64 { return (x*c.A + y*bg.A*(1-c.A)) / a }
+	return LinearColor{over(c.R, bg.R), over(c.G, bg.G), over(c.B, bg.B), a}
+}
+
+// Average returns the average of the colors weighted by their alpha, as
+// when downscaling or supersampling.
+func Average(colors ...LinearColor) LinearColor {
+	var sum LinearColor
+	for _, c := range colors {
+		sum.R += c.R * c.A
+		sum.G += c.G * c.A
+		sum.B += c.B * c.A
+		sum.A += c.A
+	}
+	if sum.A == 0 {
+		return LinearColor{}
+	}
+	return LinearColor{sum.R / sum.A, sum.G / sum.A, sum.B / sum.A, sum.A / float64(len(colors))}
+}
+
+// Downscale averages each n x n block of colors into one pixel of a width x
+// height image. Points without a color are the background bg.
+func Downscale(colors ColorResults, width, height, n int, bg LinearColor) ColorResults {
+	if n <= 1 {
+		return colors
+	}
+	pixels := make(ColorResults, width*height)
+	block := make([]LinearColor, n*n)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			for i := range block {
+				c, ok := colors[plane.ImagePoint{X: x*n + i%n, Y: y*n + i/n}]
+				if !ok {
+					c = bg
+				}
+				block[i] = c
+			}
+			pixels[plane.ImagePoint{X: x, Y: y}] = Average(block...)
+		}
+	}
+	return pixels
+}
+
+// DecodeSRGB decodes an sRGB component from 0 to 1 to linear light.
+func DecodeSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// EncodeSRGB encodes linear light, clamped to 0-1, as an sRGB component from 0 to 1.
+func EncodeSRGB(v float64) float64 {
+	v = clamp01(v)
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// SRGBToLinear decodes an 8-bit sRGB component to linear light.
+func SRGBToLinear(c uint8) float64 {
+	return DecodeSRGB(float64(c) / 0xff)
+}
+
+// LinearToSRGB encodes linear light as an 8-bit sRGB component.
+func LinearToSRGB(v float64) uint8 {
+	return uint8(math.Round(0xff * EncodeSRGB(v)))
+}
+
+// clamp01 clamps v to 0-1, treating NaN as 0.
+func clamp01(v float64) float64 {
+	if !(v > 0) {
+		return 0
+	}
+	return math.Min(v, 1)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestSRGBRoundTrip(t *testing.T) {
+	for n := 0; n <= 0xff; n++ {
+		if result := LinearToSRGB(SRGBToLinear(uint8(n))); result != uint8(n) {
+			t.Errorf("Round trip of %d gave %d", n, result)
+		}
+	}
+}
+
+func TestNRGBAClamps(t *testing.T) {
+	testCases := []struct {
+		c      LinearColor
+		expect color.NRGBA
+	}{
+		{LinearColor{-1, 2, math.NaN(), 1}, color.NRGBA{0, 0xff, 0, 0xff}},
+		{LinearColor{0.5, 0.5, 0.5, 0.5}, color.NRGBA{0xbc, 0xbc, 0xbc, 0x80}},
+	}
+	for _, tc := range testCases {
+		if result := tc.c.NRGBA(); result != tc.expect {
+			t.Errorf("%v.NRGBA() = %v; want %v", tc.c, result, tc.expect)
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	colors := ColorResults{
+		plane.ImagePoint{X: 0, Y: 0}: White,
+		plane.ImagePoint{X: 1, Y: 1}: White,
+		plane.ImagePoint{X: 2, Y: 0}: White,
+	}
+	pixels := Downscale(colors, 2, 1, 2, Black)
+	if len(pixels) != 2 {
+		t.Fatalf("Expected 2 pixels, got %d", len(pixels))
+	}
+	// Half the samples are white, half black or missing.
+	if result := pixels[plane.ImagePoint{X: 0, Y: 0}]; result != Average(White, Black) {
+		t.Errorf("Expected half white, got %v", result)
+	}
+	if result := pixels[plane.ImagePoint{X: 1, Y: 0}]; result != Average(White, Black, Black, Black) {
+		t.Errorf("Expected a quarter white, got %v", result)
+	}
+}
+
+func TestAverageIsLinear(t *testing.T) {
+	// Half black and half white is half the light, which is brighter than
+	// the sRGB midpoint.
+	if result := Average(Black, White).NRGBA(); result != (color.NRGBA{0xbc, 0xbc, 0xbc, 0xff}) {
+		t.Errorf("Average(Black, White) = %v; want #bcbcbc", result)
+	}
+	if result := Average(White, LinearColor{}); result != (LinearColor{1, 1, 1, 0.5}) {
+		t.Errorf("Expected a transparent color to only change alpha, got %v", result)
+	}
+	if result := Average(LinearColor{}, LinearColor{}); result != (LinearColor{}) {
+		t.Errorf("Expected transparent, got %v", result)
+	}
+}
+
+func TestOver(t *testing.T) {
+	red := LinearColor{1, 0, 0, 1}
+	testCases := []struct {
+		name   string
+		fg, bg LinearColor
+		expect LinearColor
+	}{
+		{"opaque", red, White, red},
+		{"transparent", LinearColor{}, White, White},
+		{"half", LinearColor{1, 0, 0, 0.5}, White, LinearColor{1, 0.5, 0.5, 1}},
+		{"both transparent", LinearColor{}, LinearColor{}, LinearColor{}},
+	}
+	for _, tc := range testCases {
+		if result := tc.fg.Over(tc.bg); result != tc.expect {
+			t.Errorf("%s: Over = %v; want %v", tc.name, result, tc.expect)
+		}
+	}
+}
+
+func TestDisplayGrayMatchesGammaCorrection(t *testing.T) {
+	// Display brightnesses come out of the sRGB encoding unchanged.
+	for _, v := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		if result, expect := DisplayGray(v).NRGBA().R, uint8(math.Round(0xff*v)); result != expect {
+			t.Errorf("DisplayGray(%v) encodes to %d; want %d", v, result, expect)
+		}
+	}
+}
+
+func TestAlphaFromBrightness(t *testing.T) {
+	if result := Black.AlphaFromBrightness(); result.A != 0 {
+		t.Errorf("Expected black to be transparent, got %v", result)
+	}
+	if result := White.AlphaFromBrightness(); !closeColor(result, White) {
+		t.Errorf("Expected white to stay opaque, got %v", result)
+	}
+
+	// Over black in sRGB it looks the same.
+	for _, c := range []LinearColor{DisplayGray(0.25), DisplayColor(0.8, 0.4, 0.1), HueColor(0.6)} {
+		result := c.AlphaFromBrightness()
+		expect := []float64{EncodeSRGB(c.R), EncodeSRGB(c.G), EncodeSRGB(c.B)}
+		for n, v := range []float64{result.R, result.G, result.B} {
+			if over := EncodeSRGB(v) * result.A; math.Abs(over-expect[n]) > 1e-9 {
+				t.Errorf("%v: channel %d over black is %v; want %v", c, n, over, expect[n])
+			}
+		}
+	}
+}
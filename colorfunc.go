@@ -9,14 +9,66 @@ import (
 
 // ColorFunc represents the alorithm used to determine the color of pixel in the image.
 type ColorFunc struct {
+	Name string
 	Desc string
 	F    func(CalcResults, ColorFuncParams) ColorResults
+
+	// Exact is set when every pixel needs its own calculated value, which
+	// rules out filling areas with Mariani-Silver subdivision.
+	Exact bool
+
+	// Detail is set when the ColorFunc uses CalcResult.Detail.
+	Detail bool
+
+	// Palette is set when the ColorFunc colors with ColorFuncParams.Palette,
+	// so it can be color cycled.
+	Palette bool
+
+	// Percentile is set when the ColorFunc clips at percentiles of the
+	// values, so it can choose its exposure automatically.
+	Percentile bool
+
+	// Relative is set when the ColorFunc normalizes against the histogram as
+	// a whole, so a region of it colors differently from the whole image.
+	Relative bool
+
+	// Pipeline is set when the ColorFunc was put together from stages.
+	Pipeline *Pipeline
 }
 
 // ColorFuncParams contains paramenters needed by a ColorFunc algorithm.
 type ColorFuncParams struct {
-	Clip, Gamma float64
-	Showclip    bool
+	Clip     float64 `json:"clip"`
+	Gamma    float64 `json:"gamma"`
+	Showclip bool    `json:"showclip,omitempty"`
+
+	// The percentile ColorFuncs clip at percentiles of the values, and can
+	// choose the gamma automatically.
+	Floor float64 `json:"floor,omitempty"` // percentile shown black
+	Auto  bool    `json:"auto,omitempty"`
+
+	Channels [NUM_CHANNELS]ChannelParams `json:"channels"` // red, green, blue
+
+	// Densities are tone mapped, then gamma corrected.
+	Tone     ToneMap `json:"tone,omitempty"`
+	Exposure float64 `json:"exposure,omitempty"` // stops
+	White    float64 `json:"white,omitempty"`    // white point as a multiple of the clip, 1 when zero
+
+	Palette *Palette `json:"palette,omitempty"` // for the palette ColorFuncs, gray when nil
+	Points  PointSet `json:"points,omitempty"`  // for equalizing and percentiles, the rest are black
+	Sectors int      `json:"sectors,omitempty"` // for decomposition and field lines, 2 when zero
+	Grid    float64  `json:"grid,omitempty"`    // grid spacing for domain coloring, none when zero
+
+	Dither      Dither `json:"dither,omitempty"`      // when quantizing to 8 bits
+	Transparent bool   `json:"transparent,omitempty"` // alpha from the normalized value, with points outside the set see-through
+
+	Limit float64 `json:"limit,omitempty"` // escape limit, set from the CalcParams when zero
+}
+
+// ChannelParams are the clip and gamma for a single channel.
+type ChannelParams struct {
+	Clip  float64 `json:"clip"`
+	Gamma float64 `json:"gamma"`
 }
 
 func (cf ColorFunc) String() string {
@@ -24,145 +76,227 @@ func (cf ColorFunc) String() string {
 }
 
 func (cfp ColorFuncParams) String() string {
-	return fmt.Sprintf("ColorFuncParams{gamma:%f, clip:%f}", cfp.Gamma, cfp.Clip)
+	return fmt.Sprintf("ColorFuncParams{gamma:%f, clip:%f, tone:%v}", cfp.Gamma, cfp.Clip, cfp.Tone)
 }
 
-// GammaScale returns a scaled and gamma corrected brightness.
+// GammaScale returns a scaled and gamma corrected display brightness. The
+// gamma shapes the brightness before it's decoded to linear light, so 2.2
+// roughly cancels out the sRGB encoding of the output.
 func GammaScale(val, max, gamma float64) float64 {
 	if gamma <= 0 {
 		gamma = 2.2 // default
 	}
-
-	scaled := complex(math.Min(val, max)/max, 0)
-	gamma_correction := complex(1.0/gamma, 0)
-	return real(cmplx.Pow(scaled, gamma_correction))
+	return math.Pow(math.Min(val, max)/max, 1/gamma)
 }
 
-var cf_luma_clip_value = ColorFunc{ //nolint:unused
-	Desc: `Brightness clips at given value`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		max := params.Clip
-		for xy, v := range histogram {
-			val := float64(v.Val)
-			if params.Showclip && val >= max+1 {
-				coloring[xy] = color.NRGBA{0xff, 0xd4, 0x79, 0xff}
-			} else {
-				brightness := uint8(255 * GammaScale(val, max, params.Gamma))
-				coloring[xy] = color.NRGBA{brightness, brightness, brightness, 0xff}
-			}
-		}
-		return coloring
-	},
+// HueColor returns a fully saturated color with the given hue in turns.
+func HueColor(hue float64) LinearColor {
+	hue = 6 * (hue - math.Floor(hue))
+	x := 1 - math.Abs(math.Mod(hue, 2)-1)
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g, b = 1, x, 0
+	case 1:
+		r, g, b = x, 1, 0
+	case 2:
+		r, g, b = 0, 1, x
+	case 3:
+		r, g, b = 0, x, 1
+	case 4:
+		r, g, b = x, 0, 1
+	default:
+		r, g, b = 1, 0, x
+	}
+	return DisplayColor(r, g, b)
 }
 
-var cf_luma_clip_percent_avg = ColorFunc{ //nolint:unused
-	Desc: `Brightness clips at given percent of max`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		max := (params.Clip / 100) * histogram.Avg()
-		for xy, v := range histogram {
-			val := float64(v.Val)
-			if params.Showclip && val >= max+1 {
-				coloring[xy] = color.NRGBA{0xff, 0xd4, 0x79, 0xff}
-			} else {
-				brightness := uint8(255 * GammaScale(val, max, params.Gamma))
-				coloring[xy] = color.NRGBA{brightness, brightness, brightness, 0xff}
-			}
-		}
-		return coloring
-	},
+// clipColor shows where values were clipped.
+var clipColor = LinearOf(color.NRGBA{0xff, 0xd4, 0x79, 0xff})
+
+// blueRamp returns the blue tinted color of a display brightness.
+func blueRamp(luma float64) LinearColor {
+	return DisplayColor(math.Min(luma*luma, 1), math.Min(luma*luma, 1), math.Min(math.Sqrt(luma), 1))
 }
 
-var cf_luma_clip_percent_max = ColorFunc{ //nolint:unused
-	Desc: `Brightness clips at given percent of max`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		max := (params.Clip / 100) * histogram.Max()
-		for xy, v := range histogram {
-			val := float64(v.Val)
-			if params.Showclip && val >= max+1 {
-				coloring[xy] = color.NRGBA{0xff, 0xd4, 0x79, 0xff}
-			} else {
-				brightness := uint8(255 * GammaScale(val, max, params.Gamma))
-				coloring[xy] = color.NRGBA{brightness, brightness, brightness, 0xff}
-			}
-		}
-		return coloring
-	},
+// palette returns the palette to use, defaulting to gray.
+func (cfp ColorFuncParams) palette() *Palette {
+	if cfp.Palette == nil {
+		return pal_gray
+	}
+	return cfp.Palette
 }
 
-var cf_escaped_1bit = ColorFunc{ //nolint:unused
-	Desc: `Escaped points are white (1bit color)`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		for xy, v := range histogram {
-			if v.Escaped {
-				coloring[xy] = color.NRGBA{0xff, 0xff, 0xff, 0xff}
-			} else {
-				coloring[xy] = color.NRGBA{0, 0, 0, 0xff}
-			}
-		}
-		return coloring
-	},
+// sectors returns the number of angular sectors, at least 2.
+func (cfp ColorFuncParams) sectors() int {
+	return max(cfp.Sectors, 2)
 }
 
-var cf_escaped_clip_value = ColorFunc{ //nolint:unused
-	Desc: `Blue brightness depends on number of iterations to escape`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		max := params.Clip
-		for xy, v := range histogram {
-			val := float64(v.Val)
-			if v.Escaped {
-				luma := GammaScale(val, max, params.Gamma)
-				rg := uint8(math.Min(255*luma*luma, 255))
-				b := uint8(math.Min(255*math.Sqrt(luma), 255))
-				coloring[xy] = color.NRGBA{rg, rg, b, 0xff}
-			} else {
-				coloring[xy] = color.NRGBA{0, 0, 0, 0xff}
-			}
-		}
-		return coloring
-	},
+// argTurns returns the argument of z in turns from 0 to 1.
+func argTurns(z complex128) float64 {
+	t := cmplx.Phase(z) / (2 * math.Pi)
+	return t - math.Floor(t)
 }
 
-var cf_escaped_clip_percent_avg = ColorFunc{ //nolint:unused
-	Desc: `Blue brightness depends on number of iterations to escape`,
+// FIELD_LINE_WIDTH is the width of field lines as a fraction of a sector.
+const FIELD_LINE_WIDTH = 0.15
+
+// golden is the golden ratio conjugate, used to spread hues for small integers.
+const golden = 0.618033988749895
+
+var cf_luma_clip_value = Pipeline{ //nolint:unused
+	Normalize: NormValue,
+	Exact:     true,
+	Showclip:  true,
+}.ColorFunc("luma_clip_value", `Brightness clips at given value`)
+
+var cf_luma_clip_percent_avg = Pipeline{ //nolint:unused
+	Normalize: NormPercentAvg,
+	Exact:     true,
+	Showclip:  true,
+}.ColorFunc("luma_clip_percent_avg", `Brightness clips at given percent of max`)
+
+var cf_luma_clip_percent_max = Pipeline{ //nolint:unused
+	Normalize: NormPercentMax,
+	Exact:     true,
+	Showclip:  true,
+}.ColorFunc("luma_clip_percent_max", `Brightness clips at given percent of max`)
+
+var cf_escaped_1bit = Pipeline{ //nolint:unused
+	Points:  EscapedPoints,
+	Escaped: Hex(White),
+}.ColorFunc("escaped_1bit", `Escaped points are white (1bit color)`)
+
+var cf_escaped_clip_value = Pipeline{ //nolint:unused
+	Points:    EscapedPoints,
+	Normalize: NormValue,
+	Ramp:      RampBlue,
+}.ColorFunc("escaped_clip_value", `Blue brightness depends on number of iterations to escape`)
+
+var cf_escaped_clip_percent_avg = Pipeline{ //nolint:unused
+	Points:    EscapedPoints,
+	Normalize: NormPercentAvg,
+	Ramp:      RampBlue,
+}.ColorFunc("escaped_clip_percent_avg", `Blue brightness depends on number of iterations to escape`)
+
+var cf_escaped_clip_percent_max = Pipeline{ //nolint:unused
+	Points:    EscapedPoints,
+	Normalize: NormPercentMax,
+	Ramp:      RampBlue,
+}.ColorFunc("escaped_clip_percent_max", `Blue brightness depends on number of iterations to escape`)
+
+// cf_channels_rgb isn't a Pipeline: a pipeline colors one value for each
+// point, and this normalizes three, each against its own clip and gamma.
+var cf_channels_rgb = ColorFunc{ //nolint:unused
+	Name: "channels_rgb",
+	Desc: `Channels are red, green and blue, each clipped at a percent of the channel's max`,
 	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
 		coloring := make(ColorResults)
-		max := (params.Clip / 100) * histogram.AvgEscaped()
+		var max [NUM_CHANNELS]float64
+		for ch, chp := range params.Channels {
+			max[ch] = (chp.Clip / 100) * histogram.MaxChannel(ch)
+		}
 		for xy, v := range histogram {
-			val := float64(v.Val)
-			if v.Escaped {
-				luma := GammaScale(val, max, params.Gamma)
-				rg := uint8(math.Min(255*luma*luma, 255))
-				b := uint8(math.Min(255*math.Sqrt(luma), 255))
-				coloring[xy] = color.NRGBA{rg, rg, b, 0xff}
-			} else {
-				coloring[xy] = color.NRGBA{0, 0, 0, 0xff}
+			var rgb [NUM_CHANNELS]float64
+			if v.Chans != nil {
+				for ch, chp := range params.Channels {
+					if max[ch] > 0 {
+						rgb[ch] = params.Scale(float64(v.Chans[ch]), max[ch], chp.Gamma)
+					}
+				}
 			}
+			coloring[xy] = DisplayColor(rgb[0], rgb[1], rgb[2])
 		}
 		return coloring
 	},
+	Exact:    true,
+	Relative: true,
 }
 
-var cf_escaped_clip_percent_max = ColorFunc{ //nolint:unused
-	Desc: `Blue brightness depends on number of iterations to escape`,
-	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
-		coloring := make(ColorResults)
-		max := (params.Clip / 100) * histogram.MaxEscaped()
-		for xy, v := range histogram {
-			val := float64(v.Val)
-			if v.Escaped {
-				luma := GammaScale(val, max, params.Gamma)
-				rg := uint8(math.Min(255*luma*luma, 255))
-				b := uint8(math.Min(255*math.Sqrt(luma), 255))
-				coloring[xy] = color.NRGBA{rg, rg, b, 0xff}
-			} else {
-				coloring[xy] = color.NRGBA{0, 0, 0, 0xff}
-			}
-		}
-		return coloring
-	},
+var cf_escaped_smooth_clip_percent_max = Pipeline{ //nolint:unused
+	Value:     ValSmooth,
+	Against:   Against(ValVal), // the highest count, not the highest smoothed value
+	Points:    EscapedPoints,
+	Normalize: NormPercentMax,
+	Ramp:      RampBlue,
+}.ColorFunc("escaped_smooth_clip_percent_max", `Blue brightness depends on the smoothed number of iterations to escape`)
+
+var cf_interior_period = Pipeline{ //nolint:unused
+	Value:     ValPeriod,
+	Points:    InteriorPoints,
+	Normalize: NormGolden,
+	Ramp:      RampHue,
+}.ColorFunc("interior_period", `Points which don't escape have a hue for the period of their cycle`)
+
+var cf_atom_domains = Pipeline{ //nolint:unused
+	Value:     ValMinIts,
+	Normalize: NormGolden,
+	Ramp:      RampHue,
+}.ColorFunc("atom_domains", `Hue depends on the iteration where the orbit came closest to 0`)
+
+var cf_escaped_decomposition = Pipeline{ //nolint:unused
+	Value:     ValAngle,
+	Points:    EscapedPoints,
+	Normalize: NormSectors,
+	Ramp:      RampPalette,
+}.ColorFunc("escaped_decomposition", `Escaped points have the palette color of the angular sector the final z is in`)
+
+var cf_escaped_field_lines = Pipeline{ //nolint:unused
+	Value:     ValSmooth,
+	Against:   Against(ValVal),
+	Points:    EscapedPoints,
+	Normalize: NormPercentMax,
+	Modulate:  ModFieldLines,
+	Ramp:      RampBlue,
+}.ColorFunc("escaped_field_lines", `Smoothed escape iterations with dark lines where the final z crosses into another angular sector`)
+
+var cf_palette_clip_value = Pipeline{ //nolint:unused
+	Normalize: NormValue,
+	Ramp:      RampPalette,
+	Showclip:  true,
+}.ColorFunc("palette_clip_value", `Palette position clips at given value`)
+
+var cf_palette_clip_percent_avg = Pipeline{ //nolint:unused
+	Normalize: NormPercentAvg,
+	Ramp:      RampPalette,
+	Showclip:  true,
+}.ColorFunc("palette_clip_percent_avg", `Palette position clips at given percent of avg`)
+
+var cf_palette_clip_percent_max = Pipeline{ //nolint:unused
+	Normalize: NormPercentMax,
+	Ramp:      RampPalette,
+	Showclip:  true,
+}.ColorFunc("palette_clip_percent_max", `Palette position clips at given percent of max`)
+
+var cf_palette_equalized = Pipeline{ //nolint:unused
+	Value:     ValSmooth, // smoothed iterations blend between the bins, when recorded
+	Against:   Against(ValVal),
+	Recorded:  true,
+	Normalize: NormEqualize,
+	Ramp:      RampPalette,
+}.ColorFunc("palette_equalized", `Palette position is the value's place in the distribution of values`)
+
+// ColorFuncs are the known ColorFuncs by name.
+var ColorFuncs = map[string]ColorFunc{
+	cf_luma_clip_value.Name:                 cf_luma_clip_value,
+	cf_luma_clip_percent_avg.Name:           cf_luma_clip_percent_avg,
+	cf_luma_clip_percent_max.Name:           cf_luma_clip_percent_max,
+	cf_escaped_1bit.Name:                    cf_escaped_1bit,
+	cf_escaped_clip_value.Name:              cf_escaped_clip_value,
+	cf_escaped_clip_percent_avg.Name:        cf_escaped_clip_percent_avg,
+	cf_escaped_clip_percent_max.Name:        cf_escaped_clip_percent_max,
+	cf_channels_rgb.Name:                    cf_channels_rgb,
+	cf_escaped_smooth_clip_percent_max.Name: cf_escaped_smooth_clip_percent_max,
+	cf_interior_period.Name:                 cf_interior_period,
+	cf_atom_domains.Name:                    cf_atom_domains,
+	cf_escaped_decomposition.Name:           cf_escaped_decomposition,
+	cf_escaped_field_lines.Name:             cf_escaped_field_lines,
+	cf_domain_modulus.Name:                  cf_domain_modulus,
+	cf_domain_rings.Name:                    cf_domain_rings,
+	cf_luma_clip_percentile.Name:            cf_luma_clip_percentile,
+	cf_palette_clip_percentile.Name:         cf_palette_clip_percentile,
+	cf_palette_clip_value.Name:              cf_palette_clip_value,
+	cf_palette_clip_percent_avg.Name:        cf_palette_clip_percent_avg,
+	cf_palette_clip_percent_max.Name:        cf_palette_clip_percent_max,
+	cf_palette_equalized.Name:               cf_palette_equalized,
 }
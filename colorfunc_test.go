@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/cmplx"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// escapedAt returns results with one escaped point for each final z.
+func escapedAt(zs ...complex128) CalcResults {
+	histogram := make(CalcResults)
+	for x, z := range zs {
+		r := histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, 10)
+		r.Escaped = true
+		r.Detail = &CalcDetail{Its: 10, ZFinal: z}
+	}
+	return histogram
+}
+
+func TestEscapedDecomposition(t *testing.T) {
+	histogram := escapedAt(
+		cmplx.Rect(3, 0.1), // upper half
+		cmplx.Rect(3, 2),
+		cmplx.Rect(3, -0.1), // lower half
+		cmplx.Rect(3, -2),
+	)
+	histogram.Add(plane.ImagePoint{X: 4, Y: 0}, 0, 10)
+
+	coloring := cf_escaped_decomposition.F(histogram, ColorFuncParams{})
+	if coloring[plane.ImagePoint{X: 0, Y: 0}] != Black || coloring[plane.ImagePoint{X: 1, Y: 0}] != Black {
+		t.Errorf("Expected the upper half plane black, got %v", coloring)
+	}
+	if coloring[plane.ImagePoint{X: 2, Y: 0}] != White || coloring[plane.ImagePoint{X: 3, Y: 0}] != White {
+		t.Errorf("Expected the lower half plane white, got %v", coloring)
+	}
+	if coloring[plane.ImagePoint{X: 4, Y: 0}] != Black {
+		t.Errorf("Expected points which didn't escape black, got %v", coloring[plane.ImagePoint{X: 4, Y: 0}])
+	}
+
+	// Four sectors step through the palette.
+	coloring = cf_escaped_decomposition.F(histogram, ColorFuncParams{Sectors: 4})
+	if a, b := coloring[plane.ImagePoint{X: 0, Y: 0}], coloring[plane.ImagePoint{X: 1, Y: 0}]; a == b {
+		t.Errorf("Expected different colors for different quadrants, got %v", a)
+	}
+}
+
+func TestEscapedFieldLines(t *testing.T) {
+	histogram := escapedAt(
+		cmplx.Rect(3, 0.001), // on the edge between sectors
+		cmplx.Rect(3, 1.5),   // in the middle of a sector
+	)
+	coloring := cf_escaped_field_lines.F(histogram, ColorFuncParams{Clip: 100, Gamma: 1, Limit: 2})
+	line, open := coloring[plane.ImagePoint{X: 0, Y: 0}], coloring[plane.ImagePoint{X: 1, Y: 0}]
+	if line.B >= open.B/10 {
+		t.Errorf("Expected a dark field line, got %v next to %v", line, open)
+	}
+}
+
+func TestDomainColoring(t *testing.T) {
+	histogram := make(CalcResults)
+	for x, w := range []complex128{1, -1, 0.5 + 0.5i, 0.63 + 0.37i, cmplx.Inf()} {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, 1).Detail = &CalcDetail{ZFinal: w}
+	}
+	at := func(coloring ColorResults, x int) LinearColor { return coloring[plane.ImagePoint{X: x, Y: 0}] }
+
+	for _, cf := range []ColorFunc{cf_domain_modulus, cf_domain_rings} {
+		coloring := cf.F(histogram, ColorFuncParams{})
+		if c := at(coloring, 0); c.R <= c.G || c.R <= c.B {
+			t.Errorf("%s: expected positive reals red, got %v", cf.Name, c)
+		}
+		if c := at(coloring, 1); c.R >= c.G || c.R >= c.B {
+			t.Errorf("%s: expected negative reals cyan, got %v", cf.Name, c)
+		}
+		if c := at(coloring, 4); c != Black {
+			t.Errorf("%s: expected infinity black, got %v", cf.Name, c)
+		}
+
+		// On a grid line, and between them.
+		lines := cf.F(histogram, ColorFuncParams{Grid: 0.5})
+		if on := at(lines, 2); on != Black {
+			t.Errorf("%s: expected black on the grid, got %v", cf.Name, on)
+		}
+		if off, plain := at(lines, 3), at(coloring, 3); off != plain {
+			t.Errorf("%s: expected %v off the grid, got %v", cf.Name, plain, off)
+		}
+	}
+}
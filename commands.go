@@ -0,0 +1,691 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// cmdRender calculates and colors a preset, optionally saving the histogram.
+func cmdRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	preset := fs.String("preset", "coldwave2", "preset to render: "+presetNames())
+	scene := fs.String("scene", "", "scene file to render instead of a preset")
+	palette_name := fs.String("palette", "", "palette for the palette ColorFuncs: a palette file or "+paletteNames())
+	out := fs.String("o", "image.png", "PNG file to write")
+	archive := fs.String("archive", "", "histogram archive to write")
+	passes := fs.Int("passes", PASSES, "progressive refinement passes")
+	mask_arg := fs.String("mask", "", "only render a region: pixel rectangle x0,y0,x1,y1 or PNG mask")
+	over := fs.String("over", "", "PNG or histogram archive to composite the masked region over")
+	store := fs.String("store", "", "sample store to write for reframing attractors later")
+	store_scale := fs.Int("store-scale", 4, "sample store bins per pixel along each axis")
+	preview := fs.Int("preview", 0, "render a quick complex64 preview at 1/N the size and iterations")
+	promote := fs.Bool("promote", false, "render the full precision scene a preview scene was made from")
+	depth := fs.Int("depth", 8, "bits per channel of the PNG: 8 or 16")
+	dither := fs.String("dither", "", "dithering when quantizing to 8 bits, instead of the scene's: "+ditherNames())
+	auto := fs.Bool("auto", false, "choose the exposure from the histogram, for the percentile ColorFuncs")
+	save_scene := fs.String("save-scene", "", "scene file to write with the exposure used")
+	transparent := fs.Bool("transparent", false, "transparent background, with alpha from the colored values")
+	fs.Parse(args) //nolint:errcheck
+	if err := checkDepth(*depth); err != nil {
+		return err
+	}
+
+	params, ok := Presets[*preset]
+	if *scene != "" {
+		var err error
+		if params, err = ReadScene(*scene); err != nil {
+			return err
+		}
+	} else if !ok {
+		return fmt.Errorf("unknown preset %q", *preset)
+	}
+	if *promote {
+		if params.Promote() == params {
+			return fmt.Errorf("-promote needs a preview scene")
+		}
+		params = params.Promote()
+	}
+
+	if *palette_name != "" {
+		palette, err := LookupPalette(*palette_name)
+		if err != nil {
+			return err
+		}
+		params.CFP.Palette = palette
+	}
+	if *dither != "" {
+		if err := params.CFP.Dither.UnmarshalText([]byte(*dither)); err != nil {
+			return err
+		}
+	}
+	if *auto {
+		if !params.CF.Percentile {
+			return fmt.Errorf("-auto needs a percentile ColorFunc, not %s", params.CF.Name)
+		}
+		params.CFP.Auto = true
+	}
+
+	if *preview > 1 {
+		if *mask_arg != "" || *store != "" {
+			return fmt.Errorf("-preview can't be used with -mask or -store")
+		}
+		params = params.Preview(*preview)
+	}
+	params.Plane.WithDepth(*depth)
+	if *transparent {
+		params.CFP.Transparent = true
+	}
+	if params.CFP.Transparent {
+		params.Plane.WithTransparent()
+	}
+
+	if *mask_arg != "" {
+		mask, err := ParseMask(*mask_arg)
+		if err != nil {
+			return err
+		}
+		params.Mask = mask
+	}
+	if *over != "" && params.Mask == nil {
+		return fmt.Errorf("-over needs a -mask")
+	}
+	// Archives and stores don't record a mask, so a masked one would pass
+	// for the whole image when merged or reframed.
+	if params.Mask != nil && *archive != "" && (*over == "" || strings.HasSuffix(*over, ".png")) {
+		return fmt.Errorf("-archive of a -mask needs -over an archive to composite into")
+	}
+	if params.Mask != nil && *store != "" {
+		return fmt.Errorf("-store can't be written for a -mask")
+	}
+
+	if *store != "" {
+		if !params.Style.Accumulates() {
+			return fmt.Errorf("-store needs an accumulating style, not %v", params.Style)
+		}
+		params.Store = NewPlaneSampleStore(params.Plane, *store_scale)
+	}
+
+	var base *Archive
+	switch {
+	case strings.HasSuffix(*over, ".png"):
+		if params.CF.Relative {
+			return fmt.Errorf("-over %s: %s normalizes against the whole histogram, which a PNG doesn't have; composite over an archive", *over, params.CF.Name)
+		}
+		if params.CFP.Dither == FloydSteinberg {
+			return fmt.Errorf("-over %s can't diffuse floyd-steinberg error across the mask's edges; dither ordered or composite over an archive", *over)
+		}
+		if err := params.Plane.ReadPNG(*over); err != nil {
+			return err
+		}
+		params.ClearMask()
+	case *over != "":
+		var err error
+		if base, err = ReadArchive(*over); err != nil {
+			return err
+		}
+	}
+
+	histogram, orbits := params.ColorImageProgressive(*passes, func(pass int) {
+		params.Plane.WritePNG(*out)
+	})
+	fmt.Printf("Rendered %d orbits\n", orbits)
+
+	if base != nil {
+		if err := base.Composite(params, histogram, orbits, params.Mask); err != nil {
+			return fmt.Errorf("%s: %w", *over, err)
+		}
+		fmt.Printf("Composited %v (%d orbits) over %s (%d orbits)\n", params.Mask, orbits, *over, base.Orbits)
+		histogram = base.Results
+		params.Plane.ResetImage()
+		params.paint(histogram, 1)
+		params.Plane.WritePNG(*out)
+	}
+	params.FreezeExposure(histogram)
+	if *save_scene != "" {
+		if err := params.WriteScene(*save_scene); err != nil {
+			return err
+		}
+	}
+
+	if *store != "" {
+		if err := params.Store.WriteStore(*store, params); err != nil {
+			return err
+		}
+	}
+
+	if *archive != "" {
+		a := &Archive{Params: params, Orbits: orbits, Results: histogram}
+		if base != nil {
+			// The base's params made most of the histogram, and the
+			// composites record the rest. It's colored as rendered.
+			a = base
+			a.Params.CF, a.Params.CFP = params.CF, params.CFP
+		}
+		return a.WriteArchive(*archive)
+	}
+	return nil
+}
+
+// cmdReframe rasterizes a sample store to a new view without recalculating.
+func cmdReframe(args []string) error {
+	fs := flag.NewFlagSet("reframe", flag.ExitOnError)
+	origin := fs.String("origin", "", "complex plane origin r,i (default is the store's)")
+	size := fs.String("size", "", "complex plane size r,i (default is the store's)")
+	height := fs.Int("height", 0, "image height (default is the store's)")
+	cf_name := fs.String("cf", "", "ColorFunc to use instead of the store's: "+colorFuncNames())
+	clip := fs.Float64("clip", 0, "clip to use instead of the store's")
+	gamma := fs.Float64("gamma", 0, "gamma to use instead of the store's")
+	palette_name := fs.String("palette", "", "palette file or name to use instead of the store's: "+paletteNames())
+	tone := fs.String("tone", "", "tone map to use instead of the store's: "+toneMapNames())
+	exposure := fs.Float64("exposure", 0, "exposure in stops to use instead of the store's")
+	white := fs.Float64("white", 0, "white point (multiple of the clip) to use instead of the store's")
+	out := fs.String("o", "image.png", "PNG file to write")
+	depth := fs.Int("depth", 8, "bits per channel of the PNG: 8 or 16")
+	transparent := fs.Bool("transparent", false, "transparent background, with alpha from the colored values")
+	dither := fs.String("dither", "", "dithering when quantizing to 8 bits, instead of the store's: "+ditherNames())
+	supersample := fs.Int("supersample", 1, "color n x n samples for each pixel, from at least 2x2 store bins each, and average them in linear light")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae reframe [flags] store.baes\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+	if err := checkDepth(*depth); err != nil {
+		return err
+	}
+	if *supersample < 1 {
+		return fmt.Errorf("-supersample must be at least 1, not %d", *supersample)
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one sample store")
+	}
+
+	store, params, err := ReadStore(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		return fmt.Errorf("sample store has no params")
+	}
+
+	p := params.Plane
+	if *origin != "" {
+		z, err := parseComplex(*origin)
+		if err != nil {
+			return err
+		}
+		p = p.NewOrigin(z)
+	}
+	if *size != "" {
+		z, err := parseComplex(*size)
+		if err != nil {
+			return err
+		}
+		p = p.NewSize(z)
+	}
+	if *height > 0 {
+		p = p.NewImageSize(int(float64(*height)*p.Aspect()), *height)
+	}
+	params.Plane = p.WithDepth(*depth)
+	if *transparent {
+		params.CFP.Transparent = true
+	}
+	if params.CFP.Transparent {
+		p.WithTransparent()
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "clip":
+			params.CFP.Clip = *clip
+		case "gamma":
+			params.CFP.Gamma = *gamma
+		case "exposure":
+			params.CFP.Exposure = *exposure
+		case "white":
+			params.CFP.White = *white
+		}
+	})
+	if *tone != "" {
+		if err := params.CFP.Tone.UnmarshalText([]byte(*tone)); err != nil {
+			return err
+		}
+	}
+	if *dither != "" {
+		if err := params.CFP.Dither.UnmarshalText([]byte(*dither)); err != nil {
+			return err
+		}
+	}
+	if *cf_name != "" {
+		cf, ok := ColorFuncs[*cf_name]
+		if !ok {
+			return fmt.Errorf("unknown ColorFunc %q", *cf_name)
+		}
+		params.CF = cf
+	}
+	if *palette_name != "" {
+		palette, err := LookupPalette(*palette_name)
+		if err != nil {
+			return err
+		}
+		params.CFP.Palette = palette
+	}
+
+	// Supersampling colors the store at n times the size, so each sample is
+	// colored on its own before they're averaged into a pixel.
+	n := *supersample
+	samples := p
+	if n > 1 {
+		samples = p.NewImageSize(n*p.ImageWidth(), n*p.ImageHeight())
+		// Samples need a few bins each or they'd have gaps where the bins
+		// don't line up with them.
+		bins := (samples.View().RealLen() / float64(samples.ImageWidth())) / (store.View.RealLen() / float64(store.Width))
+		if bins < 2-1e-9 {
+			return fmt.Errorf("-supersample %d needs 2 store bins across each sample, not %.2g", n, bins)
+		}
+	}
+	histogram := store.Rasterize(samples)
+	fmt.Printf("Rasterized %v to %v\n", store, samples)
+	histogram.PrintStats()
+	bg := Black
+	if params.CFP.Transparent {
+		bg = LinearColor{}
+	}
+	colors := Downscale(params.Colors(histogram), p.ImageWidth(), p.ImageHeight(), n, bg)
+	paintColors(p, colors, 1, params.CFP.Dither, nil)
+	p.WritePNG(*out)
+	return nil
+}
+
+// cmdScene writes a preset as a scene file to start new scenes from.
+func cmdScene(args []string) error {
+	fs := flag.NewFlagSet("scene", flag.ExitOnError)
+	preset := fs.String("preset", "coldwave2", "preset to write: "+presetNames())
+	out := fs.String("o", "scene.json", "scene file to write")
+	fs.Parse(args) //nolint:errcheck
+
+	params, ok := Presets[*preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q", *preset)
+	}
+	if err := params.WriteScene(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	return nil
+}
+
+// cmdPalettes writes the built in palettes and any palette files given as
+// strips, one above the other, to preview them.
+func cmdPalettes(args []string) error {
+	fs := flag.NewFlagSet("palettes", flag.ExitOnError)
+	width := fs.Int("width", 512, "strip width")
+	height := fs.Int("height", 32, "strip height")
+	out := fs.String("o", "palettes.png", "PNG file to write")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae palettes [flags] [palette file or directory...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+
+	var palettes []*Palette
+	for _, name := range strings.Split(paletteNames(), ", ") {
+		palettes = append(palettes, Palettes[name])
+	}
+	loaded, err := LoadPalettes(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	palettes = append(palettes, loaded...)
+
+	img := image.NewNRGBA(image.Rect(0, 0, *width, *height*len(palettes)))
+	for row, p := range palettes {
+		for x := 0; x < *width; x++ {
+			c := p.At(float64(x) / math.Max(float64(*width-1), 1)).NRGBA()
+			for y := row * *height; y < (row+1)**height; y++ {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		fmt.Printf("%3d: %v\n", row, p)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := plane.EncodePNG(f, img); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	return f.Close()
+}
+
+// cmdExtract makes a palette from the colors of a reference image.
+func cmdExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	k := fs.Int("k", 8, "number of colors")
+	seed := fs.Int64("seed", 1, "random seed, the same seed gives the same palette")
+	out := fs.String("o", "palette.json", "palette file to write (.json or .csv)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae extract [flags] image.png|image.jpg\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one image")
+	}
+	p, err := ExtractPaletteFile(fs.Arg(0), *k, *seed)
+	if err != nil {
+		return err
+	}
+	if err := p.WritePalette(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%v)\n", *out, p)
+	return nil
+}
+
+// cmdCycle writes an animation of an archive's palette turning, without
+// recalculating it.
+func cmdCycle(args []string) error {
+	fs := flag.NewFlagSet("cycle", flag.ExitOnError)
+	cf_name := fs.String("cf", "", "palette ColorFunc to use instead of the archive's: "+colorFuncNames())
+	palette_name := fs.String("palette", "", "palette file or name to use instead of the archive's: "+paletteNames())
+	frames := fs.Int("frames", 64, "frames in one turn of the palette")
+	fps := fs.Int("fps", 20, "frames per second of a GIF")
+	out := fs.String("o", "cycle.gif", "animated GIF, or numbered PNG format like frame%03d.png, to write")
+	depth := fs.Int("depth", 8, "bits per channel of PNG frames: 8 or 16")
+	dither := fs.String("dither", "", "dithering when quantizing to 8 bits, instead of the archive's: "+ditherNames())
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae cycle [flags] archive.bae\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+	if err := checkDepth(*depth); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one archive")
+	}
+	a, err := ReadArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	params := a.Params
+	if *cf_name != "" {
+		cf, ok := ColorFuncs[*cf_name]
+		if !ok {
+			return fmt.Errorf("unknown ColorFunc %q", *cf_name)
+		}
+		params.CF = cf
+	}
+	if *palette_name != "" {
+		palette, err := LookupPalette(*palette_name)
+		if err != nil {
+			return err
+		}
+		params.CFP.Palette = palette
+	}
+	if *dither != "" {
+		if err := params.CFP.Dither.UnmarshalText([]byte(*dither)); err != nil {
+			return err
+		}
+	}
+	params.Plane.WithDepth(*depth)
+
+	fmt.Printf("Cycling %d results from %d orbits with %v %v\n", len(a.Results), a.Orbits, params.CF, params.CFP)
+	return params.WriteCycle(*out, a.Results, *frames, *fps)
+}
+
+// cmdLayers calculates and colors a stack of layers and blends them into
+// one image.
+func cmdLayers(args []string) error {
+	fs := flag.NewFlagSet("layers", flag.ExitOnError)
+	out := fs.String("o", "image.png", "PNG file to write")
+	depth := fs.Int("depth", 8, "bits per channel of the PNG: 8 or 16")
+	transparent := fs.Bool("transparent", false, "transparent background, with alpha from each layer's colored values")
+	dither := fs.String("dither", "", "dithering when quantizing to 8 bits, instead of the bottom layer's: "+ditherNames())
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae layers [flags] layers.json\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+	if err := checkDepth(*depth); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one layers file")
+	}
+	layers, err := ReadLayers(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cfp := &layers[0].Params.CFP
+	if *dither != "" {
+		if err := cfp.Dither.UnmarshalText([]byte(*dither)); err != nil {
+			return err
+		}
+	}
+	p := layers[0].Params.Plane.WithDepth(*depth)
+	if *transparent {
+		p.WithTransparent()
+		for n := range layers {
+			layers[n].Params.CFP.Transparent = true
+		}
+	}
+	if err := layers.Paint(p, cfp.Dither); err != nil {
+		return err
+	}
+	p.WritePNG(*out)
+	return nil
+}
+
+// checkDepth returns an error unless depth is 8 or 16 bits per channel.
+func checkDepth(depth int) error {
+	if depth != 8 && depth != 16 {
+		return fmt.Errorf("-depth must be 8 or 16, not %d", depth)
+	}
+	return nil
+}
+
+// parseComplex parses "r,i" into a complex number.
+func parseComplex(s string) (complex128, error) {
+	var r, i float64
+	if _, err := fmt.Sscanf(s, "%g,%g", &r, &i); err != nil {
+		return 0, fmt.Errorf("bad complex number %q (want r,i): %w", s, err)
+	}
+	return complex(r, i), nil
+}
+
+// cmdMerge merges histogram archives into a new archive.
+func cmdMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("o", "merged.bae", "histogram archive to write")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae merge [-o merged.bae] archive.bae...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("no archives to merge")
+	}
+
+	merged, err := ReadArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, path := range fs.Args()[1:] {
+		a, err := ReadArchive(path)
+		if err != nil {
+			return err
+		}
+		if err := merged.Merge(a); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("Merged %s (%d orbits)\n", path, a.Orbits)
+	}
+	return merged.WriteArchive(*out)
+}
+
+// cmdColor colors a histogram archive without recalculating it.
+func cmdColor(args []string) error {
+	fs := flag.NewFlagSet("color", flag.ExitOnError)
+	cf_name := fs.String("cf", "", "ColorFunc to use instead of the archive's: "+colorFuncNames())
+	clip := fs.Float64("clip", 0, "clip to use instead of the archive's")
+	floor := fs.Float64("floor", 0, "floor percentile to use instead of the archive's")
+	auto := fs.Bool("auto", false, "choose the exposure from the histogram, for the percentile ColorFuncs")
+	save_scene := fs.String("save-scene", "", "scene file to write with the coloring used")
+	transparent := fs.Bool("transparent", false, "transparent background, with alpha from the colored values")
+	gamma := fs.Float64("gamma", 0, "gamma to use instead of the archive's")
+	palette_name := fs.String("palette", "", "palette file or name to use instead of the archive's: "+paletteNames())
+	tone := fs.String("tone", "", "tone map to use instead of the archive's: "+toneMapNames())
+	exposure := fs.Float64("exposure", 0, "exposure in stops to use instead of the archive's")
+	white := fs.Float64("white", 0, "white point (multiple of the clip) to use instead of the archive's")
+	out := fs.String("o", "image.png", "PNG file to write")
+	depth := fs.Int("depth", 8, "bits per channel of the PNG: 8 or 16")
+	dither := fs.String("dither", "", "dithering when quantizing to 8 bits, instead of the archive's: "+ditherNames())
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bae color [flags] archive.bae\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args) //nolint:errcheck
+	if err := checkDepth(*depth); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one archive")
+	}
+
+	a, err := ReadArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	params := a.Params
+	params.Plane.WithDepth(*depth)
+	if *transparent {
+		params.CFP.Transparent = true
+	}
+	if params.CFP.Transparent {
+		params.Plane.WithTransparent()
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "clip":
+			params.CFP.Clip = *clip
+		case "floor":
+			params.CFP.Floor = *floor
+		case "gamma":
+			params.CFP.Gamma = *gamma
+		case "exposure":
+			params.CFP.Exposure = *exposure
+		case "white":
+			params.CFP.White = *white
+		}
+	})
+	if *tone != "" {
+		if err := params.CFP.Tone.UnmarshalText([]byte(*tone)); err != nil {
+			return err
+		}
+	}
+	if *dither != "" {
+		if err := params.CFP.Dither.UnmarshalText([]byte(*dither)); err != nil {
+			return err
+		}
+	}
+	if *cf_name != "" {
+		cf, ok := ColorFuncs[*cf_name]
+		if !ok {
+			return fmt.Errorf("unknown ColorFunc %q", *cf_name)
+		}
+		params.CF = cf
+	}
+	if *palette_name != "" {
+		palette, err := LookupPalette(*palette_name)
+		if err != nil {
+			return err
+		}
+		params.CFP.Palette = palette
+	}
+	if params.CF.F == nil {
+		return fmt.Errorf("archive has no ColorFunc, use -cf")
+	}
+	if *auto {
+		if !params.CF.Percentile {
+			return fmt.Errorf("-auto needs a percentile ColorFunc, not %s", params.CF.Name)
+		}
+		params.CFP.Auto = true
+	}
+
+	fmt.Printf("Coloring %d results from %d orbits with %v %v\n", len(a.Results), a.Orbits, params.CF, params.CFP)
+	params.paint(a.Results, 1)
+	params.Plane.WritePNG(*out)
+	params.FreezeExposure(a.Results)
+	if *save_scene != "" {
+		return params.WriteScene(*save_scene)
+	}
+	return nil
+}
+
+func presetNames() string {
+	var names []string
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func colorFuncNames() string {
+	var names []string
+	for name := range ColorFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func paletteNames() string {
+	var names []string
+	for name := range Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func toneMapNames() string {
+	var names []string
+	for _, name := range ToneMapName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func ditherNames() string {
+	var names []string
+	for _, name := range DitherName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
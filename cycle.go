@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// CycleFrames colors the histogram once per frame, turning the palette one
+// period over the frames as in classic color cycling. Nothing is calculated
+// again, only colored. Palettes which aren't cyclic blend from their last
+// stop back to the first. Each frame's image is passed to frame and is reused
+// for the next frame.
+func (cp *CalcParams) CycleFrames(histogram CalcResults, frames int, frame func(n int, img image.Image) error) error {
+	if !cp.CF.Palette {
+		return fmt.Errorf("ColorFunc %s doesn't use a palette so can't be cycled", cp.CF.Name)
+	}
+	if frames < 1 {
+		return fmt.Errorf("need at least 1 frame, not %d", frames)
+	}
+
+	palette := cp.CFP.palette()
+	period := palette.Period
+	if period <= 0 {
+		period = 1
+	}
+	params := *cp
+	for n := 0; n < frames; n++ {
+		offset := palette.Offset + period*float64(n)/float64(frames)
+		params.CFP.Palette = palette.WithCycle(offset, period)
+		params.Plane.ResetImage()
+		params.paint(histogram, 1)
+		if err := frame(n, params.Plane.Image()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gifPalette returns the 256 colors to quantize frames of the cycling
+// palette to: black, the clip color and samples around the palette.
+func gifPalette(p *Palette) color.Palette {
+	colors := color.Palette{Black.NRGBA(), clipColor.NRGBA()}
+	samples := 256 - len(colors)
+	for n := 0; n < samples; n++ {
+		colors = append(colors, p.At(float64(n)/float64(samples)).NRGBA())
+	}
+	return colors
+}
+
+// WriteCycle colors a palette cycle of the histogram to path. A .gif path
+// gets an animated GIF at the given frames per second. Any other path is a
+// Printf format for the frame number of a PNG sequence, like frame%03d.png.
+// GIFs can't go faster than 100 frames per second.
+func (cp *CalcParams) WriteCycle(path string, histogram CalcResults, frames, fps int) error {
+	if strings.ToLower(filepath.Ext(path)) != ".gif" {
+		if !strings.Contains(path, "%") {
+			return fmt.Errorf("%s: want a .gif or a numbered PNG format like frame%%03d.png", path)
+		}
+		return cp.CycleFrames(histogram, frames, func(n int, img image.Image) error {
+			name := fmt.Sprintf(path, n)
+			f, err := os.Create(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := plane.EncodePNG(f, img); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			fmt.Printf("Wrote %s\n", name)
+			return nil
+		})
+	}
+
+	if fps < 1 {
+		fps = 1
+	}
+	// Delays are in hundredths of a second, and browsers slow down 0.
+	delay := max(100/fps, 1)
+	// The palette only turns so every frame has the same colors.
+	colors := gifPalette(cp.CFP.palette().WithCycle(0, 1))
+	anim := gif.GIF{Config: image.Config{ColorModel: colors}}
+	err := cp.CycleFrames(histogram, frames, func(n int, img image.Image) error {
+		paletted := image.NewPaletted(img.Bounds(), colors)
+		draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	anim.Config.Width, anim.Config.Height = cp.Plane.ImageWidth(), cp.Plane.ImageHeight()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, &anim); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Printf("Wrote %s (%d frames)\n", path, frames)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func cycleParams() (*CalcParams, CalcResults) {
+	params := &CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(4, 4), 4),
+		CF:    cf_palette_clip_value,
+		CFP:   ColorFuncParams{Clip: 4, Gamma: 1, Palette: pal_rainbow},
+	}
+	histogram := make(CalcResults)
+	for x := 0; x < 4; x++ {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, uint(x+1))
+	}
+	return params, histogram
+}
+
+func TestCycleFrames(t *testing.T) {
+	params, histogram := cycleParams()
+	params.paint(histogram, 1)
+	still := bytes.Clone(params.Plane.Image().(*image.NRGBA).Pix)
+
+	var frames [][]byte
+	err := params.CycleFrames(histogram, 4, func(n int, img image.Image) error {
+		frames = append(frames, bytes.Clone(img.(*image.NRGBA).Pix))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("Expected 4 frames, got %d", len(frames))
+	}
+	if !bytes.Equal(frames[0], still) {
+		t.Error("Expected the first frame to match the still image")
+	}
+	for n := 1; n < len(frames); n++ {
+		if bytes.Equal(frames[n], frames[n-1]) {
+			t.Errorf("Frame %d is the same as frame %d", n, n-1)
+		}
+	}
+	if params.CFP.Palette != pal_rainbow {
+		t.Error("Expected the params' palette to be left alone")
+	}
+
+	params.CF = cf_luma_clip_value
+	if err := params.CycleFrames(histogram, 4, func(int, image.Image) error { return nil }); err == nil {
+		t.Error("Expected an error cycling a ColorFunc without a palette")
+	}
+}
+
+func TestWriteCycle(t *testing.T) {
+	params, histogram := cycleParams()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "cycle.gif")
+	if err := params.WriteCycle(path, histogram, 5, 10); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 5 || anim.Delay[0] != 10 {
+		t.Errorf("Expected 5 frames 10/100s apart, got %d with delay %d", len(anim.Image), anim.Delay[0])
+	}
+
+	// Faster than a GIF can go is as fast as it can go, not the default.
+	fast := filepath.Join(dir, "fast.gif")
+	if err := params.WriteCycle(fast, histogram, 2, 200); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim, err = gif.DecodeAll(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if anim.Delay[0] != 1 {
+		t.Errorf("Expected a delay of 1/100s at 200fps, got %d", anim.Delay[0])
+	}
+
+	if err := params.WriteCycle(filepath.Join(dir, "frame%02d.png"), histogram, 3, 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"frame00.png", "frame01.png", "frame02.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := params.WriteCycle(filepath.Join(dir, "frame.png"), histogram, 3, 10); err == nil {
+		t.Error("Expected an error for a PNG path without a frame number")
+	}
+}
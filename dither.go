@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// Dither is how colors are dithered when they're quantized to 8 bits per
+// channel, trading banding in smooth gradients for fine noise.
+type Dither int
+
+const (
+	NoDither       Dither = iota
+	OrderedDither         // an 8x8 Bayer threshold matrix tiled over the image
+	FloydSteinberg        // error diffusion across each row and down
+)
+
+var DitherName = map[int]string{
+	int(NoDither):       "none",
+	int(OrderedDither):  "ordered",
+	int(FloydSteinberg): "floyd-steinberg",
+}
+
+func (d Dither) String() string {
+	return DitherName[int(d)]
+}
+
+func (d Dither) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Dither) UnmarshalText(text []byte) error {
+	for n, name := range DitherName {
+		if name == string(text) {
+			*d = Dither(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dither: %q", text)
+}
+
+// bayer is an 8x8 ordered dithering matrix of thresholds from 0 to 63.
+var bayer = func() (m [8][8]int) {
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			// Interleave the bits of x^y and y, reversed.
+			v, xy := 0, x^y
+			for bit := 0; bit < 3; bit++ {
+				v = v<<2 | (xy>>bit&1)<<1 | y>>bit&1
+			}
+			m[y][x] = v
+		}
+	}
+	return
+}()
+
+// quantize encodes a linear component as an 8-bit sRGB level, rounding up
+// when the fraction is at least threshold. A threshold of 0.5 rounds.
+func quantize(v, threshold float64) uint8 {
+	return uint8(math.Min(math.Floor(0xff*EncodeSRGB(v)+threshold), 0xff))
+}
+
+// Quantize encodes the colors as 8-bit sRGB, dithered. Ordered dithering
+// only depends on each pixel's position, so a tile or masked region quantizes
+// the same as those pixels of the whole image. Floyd-Steinberg scans rows in
+// order and the error it diffuses depends on the neighbors, so it's only
+// given whole images: every pass repaints all of the histogram, and masked
+// renders refuse it over a PNG.
+func (d Dither) Quantize(colors ColorResults) map[plane.ImagePoint]color.NRGBA {
+	quantized := make(map[plane.ImagePoint]color.NRGBA, len(colors))
+	switch d {
+	case OrderedDither:
+		for pt, c := range colors {
+			threshold := (float64(bayer[pt.Y&7][pt.X&7]) + 0.5) / 64
+			quantized[pt] = color.NRGBA{
+				quantize(c.R, threshold), quantize(c.G, threshold), quantize(c.B, threshold),
+				uint8(math.Round(0xff * clamp01(c.A))),
+			}
+		}
+	case FloydSteinberg:
+		diffuse(colors, quantized)
+	default:
+		for pt, c := range colors {
+			quantized[pt] = c.NRGBA()
+		}
+	}
+	return quantized
+}
+
+// diffuse quantizes the colors with Floyd-Steinberg error diffusion. Error is
+// only passed on to neighbors which have colors.
+func diffuse(colors ColorResults, quantized map[plane.ImagePoint]color.NRGBA) {
+	points := make([]plane.ImagePoint, 0, len(colors))
+	for pt := range colors {
+		points = append(points, pt)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Y != points[j].Y {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+
+	// Errors are in 8-bit sRGB levels.
+	errs := make(map[plane.ImagePoint][3]float64)
+	neighbors := []struct {
+		dx, dy int
+		weight float64
+	}{{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16}}
+
+	for _, pt := range points {
+		c := colors[pt]
+		e := errs[pt]
+		delete(errs, pt)
+
+		var levels [3]uint8
+		for ch, v := range [3]float64{c.R, c.G, c.B} {
+			want := 0xff*EncodeSRGB(v) + e[ch]
+			levels[ch] = uint8(math.Max(0, math.Min(math.Round(want), 0xff)))
+			e[ch] = want - float64(levels[ch])
+		}
+		quantized[pt] = color.NRGBA{levels[0], levels[1], levels[2], uint8(math.Round(0xff * clamp01(c.A)))}
+
+		for _, n := range neighbors {
+			next := plane.ImagePoint{X: pt.X + n.dx, Y: pt.Y + n.dy}
+			if _, ok := colors[next]; !ok {
+				continue
+			}
+			ne := errs[next]
+			for ch := range ne {
+				ne[ch] += n.weight * e[ch]
+			}
+			errs[next] = ne
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestBayer(t *testing.T) {
+	seen := make(map[int]bool)
+	for _, row := range bayer {
+		for _, v := range row {
+			seen[v] = true
+		}
+	}
+	if len(seen) != 64 {
+		t.Errorf("Expected thresholds 0 to 63 once each, got %d distinct", len(seen))
+	}
+	if expect := [8]int{0, 32, 8, 40, 2, 34, 10, 42}; bayer[0] != expect {
+		t.Errorf("Expected first row %v, got %v", expect, bayer[0])
+	}
+}
+
+// flat returns a size x size square of one color a fraction between two
+// 8-bit sRGB levels.
+func flat(size int, level float64) ColorResults {
+	colors := make(ColorResults)
+	v := DecodeSRGB(level / 0xff)
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			colors[plane.ImagePoint{X: x, Y: y}] = LinearColor{v, v, v, 1}
+		}
+	}
+	return colors
+}
+
+func TestDitherAveragesToTheColor(t *testing.T) {
+	const level = 20.3
+	colors := flat(32, level)
+	for n := range DitherName {
+		d := Dither(n)
+		var sum float64
+		distinct := make(map[uint8]bool)
+		for _, c := range d.Quantize(colors) {
+			sum += float64(c.G)
+			distinct[c.G] = true
+		}
+		mean := sum / float64(len(colors))
+
+		if d == NoDither {
+			if mean != 20 || len(distinct) != 1 {
+				t.Errorf("%v: expected every level 20, got mean %v", d, mean)
+			}
+			continue
+		}
+		if math.Abs(mean-level) > 0.05 {
+			t.Errorf("%v: mean level %v; want %v", d, mean, level)
+		}
+		if len(distinct) != 2 {
+			t.Errorf("%v: expected levels 20 and 21, got %v", d, distinct)
+		}
+	}
+}
+
+func TestDitherConsistentAcrossTiles(t *testing.T) {
+	colors := flat(16, 100.6)
+	for n := range DitherName {
+		d := Dither(n)
+		// The same colors always quantize the same way.
+		whole := d.Quantize(colors)
+		again := d.Quantize(colors)
+		for pt, c := range whole {
+			if again[pt] != c {
+				t.Fatalf("%v: %v quantized to %v then %v", d, pt, c, again[pt])
+			}
+		}
+	}
+
+	// Ordered dithering a tile matches the same pixels of the whole image.
+	whole := OrderedDither.Quantize(colors)
+	tile := make(ColorResults)
+	for pt, c := range colors {
+		if pt.X >= 5 && pt.Y >= 3 {
+			tile[pt] = c
+		}
+	}
+	for pt, c := range OrderedDither.Quantize(tile) {
+		if whole[pt] != c {
+			t.Errorf("Tile pixel %v is %v; want %v", pt, c, whole[pt])
+		}
+	}
+}
+
+func TestPaintBlocksDithered(t *testing.T) {
+	p := plane.NewPlane(complex(0, 0), complex(4, 4), 8)
+	colors := flat(8, 100.5)
+	paintColors(p, colors, 4, OrderedDither, nil)
+
+	img := p.Image()
+	levels := make(map[uint32]bool)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			levels[r>>8] = true
+		}
+	}
+	if !levels[100] || !levels[101] || len(levels) != 2 {
+		t.Errorf("Expected blocks dithered between levels 100 and 101, got %v", levels)
+	}
+}
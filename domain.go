@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// GRID_LINE_WIDTH is the width of domain coloring grid lines as a fraction
+// of the grid spacing.
+const GRID_LINE_WIDTH = 0.06
+
+// domainHue returns the hue of w's argument, with red for positive reals.
+func domainHue(w complex128) LinearColor {
+	return HueColor(argTurns(w))
+}
+
+// shade returns c with its display brightness scaled by f from 0 to 1.
+func shade(c LinearColor, f float64) LinearColor {
+	return c.Mix(Black, 1-DecodeSRGB(clamp01(f)))
+}
+
+// gridLines darkens c where w is close to a multiple of grid along either
+// axis, showing how the map bends straight lines. There are no lines when
+// grid isn't positive.
+func gridLines(c LinearColor, w complex128, grid float64) LinearColor {
+	if grid <= 0 {
+		return c
+	}
+	dist := func(v float64) float64 {
+		v /= grid
+		return math.Abs(v - math.Round(v))
+	}
+	if d := math.Min(dist(real(w)), dist(imag(w))); d < GRID_LINE_WIDTH {
+		return shade(c, d/GRID_LINE_WIDTH)
+	}
+	return c
+}
+
+// domainColoring colors the final z of each point with color, drawing grid
+// lines over it. Points without a final z or where it's infinite are black.
+func domainColoring(histogram CalcResults, params ColorFuncParams, color func(w complex128) LinearColor) ColorResults {
+	coloring := make(ColorResults)
+	for xy, v := range histogram {
+		if v.Detail == nil || cmplx.IsInf(v.Detail.ZFinal) || cmplx.IsNaN(v.Detail.ZFinal) {
+			coloring[xy] = Black
+			continue
+		}
+		w := v.Detail.ZFinal
+		coloring[xy] = gridLines(color(w), w, params.Grid)
+	}
+	return coloring
+}
+
+var cf_domain_modulus = ColorFunc{ //nolint:unused
+	Name: "domain_modulus",
+	Desc: `Hue is the argument of f^n(z), lightness goes from black at 0 to white at infinity`,
+	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
+		return domainColoring(histogram, params, func(w complex128) LinearColor {
+			light := 2 / math.Pi * math.Atan(cmplx.Abs(w))
+			if light < 0.5 {
+				return shade(domainHue(w), 2*light)
+			}
+			return domainHue(w).Mix(White, DecodeSRGB(2*light-1))
+		})
+	},
+	Exact:  true,
+	Detail: true,
+}
+
+var cf_domain_rings = ColorFunc{ //nolint:unused
+	Name: "domain_rings",
+	Desc: `Hue is the argument of f^n(z), shaded in rings where the modulus doubles`,
+	F: func(histogram CalcResults, params ColorFuncParams) ColorResults {
+		return domainColoring(histogram, params, func(w complex128) LinearColor {
+			ring := math.Log2(cmplx.Abs(w))
+			return shade(domainHue(w), 0.5+0.5*(ring-math.Floor(ring)))
+		})
+	},
+	Exact:  true,
+	Detail: true,
+}
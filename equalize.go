@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PointSet selects which points a ColorFunc uses.
+type PointSet int
+
+const (
+	AllPoints      PointSet = iota
+	EscapedPoints           // points which escaped
+	InteriorPoints          // points which didn't escape
+)
+
+var PointSetName = map[int]string{
+	int(AllPoints):      "all",
+	int(EscapedPoints):  "escaped",
+	int(InteriorPoints): "interior",
+}
+
+func (ps PointSet) String() string {
+	return PointSetName[int(ps)]
+}
+
+func (ps PointSet) MarshalText() ([]byte, error) {
+	return []byte(ps.String()), nil
+}
+
+func (ps *PointSet) UnmarshalText(text []byte) error {
+	for n, name := range PointSetName {
+		if name == string(text) {
+			*ps = PointSet(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown point set: %q", text)
+}
+
+// Has returns whether the result is in the set.
+func (ps PointSet) Has(r *CalcResult) bool {
+	switch ps {
+	case EscapedPoints:
+		return r.Escaped
+	case InteriorPoints:
+		return !r.Escaped
+	}
+	return true
+}
+
+// Equalizer maps values to their place in the cumulative distribution of a
+// histogram, spreading skewed values evenly from 0 to 1.
+type Equalizer struct {
+	vals []float64 // distinct values in order
+	cdf  []float64 // fraction of points below each value plus half those at it
+}
+
+// NewEqualizer returns an Equalizer for the values of the points in the set.
+func NewEqualizer(histogram CalcResults, points PointSet) *Equalizer {
+	var vals []float64
+	for _, r := range histogram {
+		if points.Has(r) {
+			vals = append(vals, float64(r.Val))
+		}
+	}
+	return newEqualizer(vals)
+}
+
+// newEqualizer returns an Equalizer for the values, which it sorts.
+func newEqualizer(vals []float64) *Equalizer {
+	sort.Float64s(vals)
+
+	eq := &Equalizer{}
+	total := float64(len(vals))
+	for n := 0; n < len(vals); {
+		end := n
+		for end < len(vals) && vals[end] == vals[n] {
+			end++
+		}
+		eq.vals = append(eq.vals, vals[n])
+		eq.cdf = append(eq.cdf, (float64(n)+float64(end-n)/2)/total)
+		n = end
+	}
+	return eq
+}
+
+// At returns the equalized value of val. Values between those in the
+// histogram are interpolated between their neighbors.
+func (eq *Equalizer) At(val float64) float64 {
+	if len(eq.vals) == 0 {
+		return 0
+	}
+	n := sort.SearchFloat64s(eq.vals, val)
+	switch {
+	case n == len(eq.vals):
+		return eq.cdf[n-1]
+	case eq.vals[n] == val || n == 0:
+		return eq.cdf[n]
+	}
+	f := (val - eq.vals[n-1]) / (eq.vals[n] - eq.vals[n-1])
+	return eq.cdf[n-1] + f*(eq.cdf[n]-eq.cdf[n-1])
+}
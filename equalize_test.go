@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func equalizeHistogram() CalcResults {
+	// Extremely skewed values, with the biggest two escaped.
+	histogram := make(CalcResults)
+	for n, val := range []uint{1, 1, 2, 4, 1000, 1000000} {
+		histogram.Add(plane.ImagePoint{X: n, Y: 0}, 0, val).Escaped = n >= 4
+	}
+	return histogram
+}
+
+func TestEqualizer(t *testing.T) {
+	eq := NewEqualizer(equalizeHistogram(), AllPoints)
+	testCases := []struct {
+		val, expect float64
+	}{
+		{0, 1.0 / 6},
+		{1, 1.0 / 6},
+		{2, 2.5 / 6},
+		{3, 3.0 / 6}, // between the bins
+		{4, 3.5 / 6},
+		{1000, 4.5 / 6},
+		{1000000, 5.5 / 6},
+		{2000000, 5.5 / 6},
+	}
+	for _, tc := range testCases {
+		if result := eq.At(tc.val); result != tc.expect {
+			t.Errorf("At(%v) = %v; want %v", tc.val, result, tc.expect)
+		}
+	}
+}
+
+func TestEqualizerPoints(t *testing.T) {
+	histogram := equalizeHistogram()
+	if result := NewEqualizer(histogram, EscapedPoints).At(1000); result != 0.25 {
+		t.Errorf("Escaped At(1000) = %v; want 0.25", result)
+	}
+	if result := NewEqualizer(histogram, InteriorPoints).At(4); result != 7.0/8 {
+		t.Errorf("Interior At(4) = %v; want %v", result, 7.0/8)
+	}
+	if result := NewEqualizer(make(CalcResults), AllPoints).At(4); result != 0 {
+		t.Errorf("Empty At(4) = %v; want 0", result)
+	}
+}
+
+func TestPaletteEqualizedSpreadsSkewedValues(t *testing.T) {
+	histogram := equalizeHistogram()
+	coloring := cf_palette_equalized.F(histogram, ColorFuncParams{})
+	// With gray, the evenly spread ranks step up in brightness even
+	// though the values jump by orders of magnitude.
+	last := -1
+	for x := 1; x < 6; x++ {
+		c := coloring[plane.ImagePoint{X: x, Y: 0}].NRGBA()
+		if int(c.R) <= last {
+			t.Errorf("Expected %v at %d to be brighter than %d", c, x, last)
+		}
+		last = int(c.R)
+	}
+
+	coloring = cf_palette_equalized.F(histogram, ColorFuncParams{Points: EscapedPoints})
+	if c := coloring[plane.ImagePoint{X: 0, Y: 0}]; c != Black {
+		t.Errorf("Expected points outside the set to be black, got %v", c)
+	}
+}
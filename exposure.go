@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	AUTO_CLIP   = 99.5 // percentile shown white when the clip is zero and there's no knee
+	AUTO_MEDIAN = 0.3  // display brightness auto exposure puts the median at
+
+	// Auto exposure clips at the knee of the tail of the values, between
+	// these percentiles, when the tail bends by at least AUTO_KNEE.
+	AUTO_CLIP_MIN = 95.0
+	AUTO_CLIP_MAX = 99.9
+	AUTO_KNEE     = 0.25
+
+	AUTO_GAMMA_MIN = 0.25
+	AUTO_GAMMA_MAX = 16.0
+)
+
+// percentileRange returns the values at the floor and clip percentiles of
+// the values, which it sorts. The clip defaults to AUTO_CLIP.
+func (cfp ColorFuncParams) percentileRange(vals []float64) (lo, hi float64) {
+	clip := cfp.Clip
+	if clip <= 0 {
+		clip = AUTO_CLIP
+	}
+	ps := percentiles(vals, cfp.Floor, clip)
+	lo, hi = ps[0], ps[1]
+	if !(hi > lo) {
+		hi = lo + 1 // a single value is white
+	}
+	return
+}
+
+// AutoExposure returns the params with the clip, when it's zero, chosen at
+// the knee of the tail of the values, and the gamma chosen so the median
+// value has a display brightness of AUTO_MEDIAN between the floor and clip
+// percentiles. The params it returns aren't automatic, so they can be kept
+// in scenes and archives.
+func (cfp ColorFuncParams) AutoExposure(vals []float64) ColorFuncParams {
+	auto := cfp
+	auto.Auto = false
+	if auto.Clip <= 0 {
+		sort.Float64s(vals)
+		auto.Clip = autoClip(vals)
+	}
+	lo, hi := auto.percentileRange(vals)
+	median := percentiles(vals, 50)[0]
+
+	// Scale with a gamma of 1 is the brightness before gamma correction.
+	auto.Gamma = 1
+	if m := auto.Scale(median-lo, hi-lo, 1); m > 0 && m < 1 {
+		auto.Gamma = math.Log(m) / math.Log(AUTO_MEDIAN)
+	}
+	auto.Gamma = math.Max(AUTO_GAMMA_MIN, math.Min(auto.Gamma, AUTO_GAMMA_MAX))
+	return auto
+}
+
+// autoClip returns the percentile at the knee of the sorted values' tail,
+// where they turn from climbing slowly to climbing steeply towards the
+// hottest pixels. The tail from AUTO_CLIP_MIN to the max is scaled to a unit
+// square and the knee is where it falls furthest below the diagonal. A tail
+// which doesn't bend by AUTO_KNEE has no knee, and clips at AUTO_CLIP.
+func autoClip(sorted []float64) float64 {
+	if len(sorted) < 2 {
+		return AUTO_CLIP
+	}
+	last := len(sorted) - 1
+	start := int(AUTO_CLIP_MIN / 100 * float64(last))
+	span := sorted[last] - sorted[start]
+	if start == last || !(span > 0) {
+		return AUTO_CLIP
+	}
+
+	knee, bend := last, 0.0
+	for n := start; n <= last; n++ {
+		x := float64(n-start) / float64(last-start)
+		y := (sorted[n] - sorted[start]) / span
+		if x-y > bend {
+			knee, bend = n, x-y
+		}
+	}
+	if bend < AUTO_KNEE {
+		return AUTO_CLIP
+	}
+	return math.Min(100*float64(knee)/float64(last), AUTO_CLIP_MAX)
+}
+
+// autoExposure returns cfp with the exposure chosen for the values the
+// ColorFunc colors, if it's automatic.
+func (cp *CalcParams) autoExposure(histogram CalcResults, cfp ColorFuncParams) ColorFuncParams {
+	if !cfp.Auto || !cp.CF.Percentile || cp.CF.Pipeline == nil {
+		return cfp
+	}
+	_, vals := cp.CF.Pipeline.values(histogram, cfp)
+	return cfp.AutoExposure(vals)
+}
+
+// FreezeExposure replaces automatic exposure with the clip and gamma it
+// chooses for the histogram, printing them.
+func (cp *CalcParams) FreezeExposure(histogram CalcResults) {
+	cfp := cp.CFP
+	if cfp.Limit == 0 {
+		cfp.Limit = cp.Limit
+	}
+	auto := cp.autoExposure(histogram, cfp)
+	if auto.Auto == cfp.Auto {
+		return
+	}
+	auto.Limit = cp.CFP.Limit
+	cp.CFP = auto
+	fmt.Printf("Auto exposure: floor p%g, clip p%g, gamma %.3f\n", auto.Floor, auto.Clip, auto.Gamma)
+}
+
+var cf_luma_clip_percentile = Pipeline{ //nolint:unused
+	Normalize: NormPercentile,
+	Ramp:      RampGray,
+	Exact:     true,
+	Showclip:  true,
+}.ColorFunc("luma_clip_percentile", `Brightness goes from black at the floor percentile of values to white at the clip percentile`)
+
+var cf_palette_clip_percentile = Pipeline{ //nolint:unused
+	Normalize: NormPercentile,
+	Ramp:      RampPalette,
+	Exact:     true,
+	Showclip:  true,
+}.ColorFunc("palette_clip_percentile", `Palette position goes from the start at the floor percentile of values to the end at the clip percentile`)
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// densities returns results with vals 1 to n and one hot pixel.
+func densities(n int, hot uint) CalcResults {
+	histogram := make(CalcResults)
+	for x := 0; x < n; x++ {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, uint(x+1))
+	}
+	histogram.Add(plane.ImagePoint{X: n, Y: 0}, 0, hot)
+	return histogram
+}
+
+func TestPercentiles(t *testing.T) {
+	histogram := make(CalcResults)
+	for x, val := range []uint{10, 20, 30, 40, 50} {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, val).Escaped = x%2 == 0
+	}
+	result := histogram.Percentiles(AllPoints, 0, 50, 100, 12.5)
+	for n, expect := range []float64{10, 30, 50, 15} {
+		if result[n] != expect {
+			t.Errorf("Percentiles()[%d] = %v; want %v", n, result[n], expect)
+		}
+	}
+	if result := histogram.Percentiles(InteriorPoints, 50); result[0] != 30 {
+		t.Errorf("Interior median = %v; want 30", result[0])
+	}
+	if result := make(CalcResults).Percentiles(AllPoints, 50); !math.IsNaN(result[0]) {
+		t.Errorf("Expected NaN for no points, got %v", result[0])
+	}
+}
+
+func TestPercentileClipIgnoresHotPixels(t *testing.T) {
+	params := ColorFuncParams{Clip: 99, Gamma: 1}
+	cool := cf_luma_clip_percentile.F(densities(999, 1000), params)
+	hot := cf_luma_clip_percentile.F(densities(999, 1e9), params)
+	for x := 0; x < 999; x++ {
+		xy := plane.ImagePoint{X: x, Y: 0}
+		if cool[xy] != hot[xy] {
+			t.Fatalf("Pixel %d changed with a hotter hot pixel: %v != %v", x, cool[xy], hot[xy])
+		}
+	}
+
+	// Values up to the floor are black.
+	params.Floor = 10
+	coloring := cf_luma_clip_percentile.F(densities(999, 1000), params)
+	if c := coloring[plane.ImagePoint{X: 50, Y: 0}]; c != Black {
+		t.Errorf("Expected black below the floor, got %v", c)
+	}
+	if c := coloring[plane.ImagePoint{X: 200, Y: 0}]; c == Black {
+		t.Error("Expected above the floor to be brighter than black")
+	}
+}
+
+func TestAutoExposure(t *testing.T) {
+	histogram := densities(999, 1e9)
+	params := ColorFuncParams{Auto: true}
+	_, vals := cf_luma_clip_percentile.Pipeline.values(histogram, params)
+	auto := params.AutoExposure(vals)
+	if auto.Auto || auto.Clip < AUTO_CLIP_MIN || auto.Clip > AUTO_CLIP_MAX {
+		t.Errorf("Expected fixed params clipping from p%v to p%v, got %+v", AUTO_CLIP_MIN, AUTO_CLIP_MAX, auto)
+	}
+
+	lo, hi := auto.percentileRange(vals)
+	if hi > 999 {
+		t.Errorf("Expected the clip below the hot pixel, got %v", hi)
+	}
+	median := histogram.Percentiles(AllPoints, 50)[0]
+	if luma := auto.Scale(median-lo, hi-lo, auto.Gamma); math.Abs(luma-AUTO_MEDIAN) > 1e-9 {
+		t.Errorf("Median brightness %v; want %v", luma, AUTO_MEDIAN)
+	}
+
+	// Rendering with auto exposure is the same as with the frozen params.
+	cp := CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(4, 4), 4),
+		CF:    cf_luma_clip_percentile,
+		CFP:   params,
+	}
+	colors := cp.Colors(histogram)
+	cp.FreezeExposure(histogram)
+	if cp.CFP != auto {
+		t.Errorf("Froze %+v; want %+v", cp.CFP, auto)
+	}
+	for xy, c := range cp.Colors(histogram) {
+		if colors[xy] != c {
+			t.Fatalf("Frozen color at %v is %v; want %v", xy, c, colors[xy])
+		}
+	}
+}
+
+func TestAutoClip(t *testing.T) {
+	sorted := func(histogram CalcResults) []float64 {
+		_, vals := cf_luma_clip_percentile.Pipeline.values(histogram, ColorFuncParams{})
+		sort.Float64s(vals)
+		return vals
+	}
+
+	// An even spread has no knee.
+	if result := autoClip(sorted(densities(1000, 1001))); result != AUTO_CLIP {
+		t.Errorf("autoClip(even) = %v; want %v", result, AUTO_CLIP)
+	}
+
+	// The tail bends where a few hot pixels start.
+	histogram := densities(1000, 1)
+	for x := 0; x < 5; x++ {
+		histogram.Add(plane.ImagePoint{X: x, Y: 1}, 0, 1e6)
+	}
+	vals := sorted(histogram)
+	clip := autoClip(vals)
+	if hi := percentiles(vals, clip)[0]; hi > 1000 || clip < AUTO_CLIP_MIN {
+		t.Errorf("autoClip(hot) = p%v at %v; want at most 1000", clip, hi)
+	}
+
+	// A clip that's given is kept.
+	if auto := (ColorFuncParams{Clip: 90}).AutoExposure(vals); auto.Clip != 90 {
+		t.Errorf("Expected the given clip to be kept, got %v", auto.Clip)
+	}
+}
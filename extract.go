@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // for reading reference images
+	_ "image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	EXTRACT_SAMPLES    = 1 << 16 // most pixels clustered
+	EXTRACT_ITERATIONS = 64      // most k-means iterations
+)
+
+// labColor is a color in OKLab.
+type labColor [3]float64
+
+func labOf(c color.Color) labColor {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	l := LinearOf(n)
+	L, A, B := LinearToOKLab(l.R, l.G, l.B)
+	return labColor{L, A, B}
+}
+
+func (c labColor) NRGBA() color.NRGBA {
+	r, g, b := OKLabToLinear(c[0], c[1], c[2])
+	return LinearColor{r, g, b, 1}.NRGBA()
+}
+
+func (c labColor) dist2(d labColor) float64 {
+	return (c[0]-d[0])*(c[0]-d[0]) + (c[1]-d[1])*(c[1]-d[1]) + (c[2]-d[2])*(c[2]-d[2])
+}
+
+// ExtractPalette clusters the colors of img into k colors with k-means in
+// OKLab and orders them into a gradient from the darkest, each followed by
+// the closest remaining color. Stops are spaced by how different the colors
+// are. The same seed always gives the same palette.
+func ExtractPalette(img image.Image, k int, seed int64) (*Palette, error) {
+	if k < 2 {
+		return nil, fmt.Errorf("need at least 2 colors, not %d", k)
+	}
+
+	// Sample pixels on an even grid, skipping transparent ones.
+	r := img.Bounds()
+	step := math.Max(1, math.Sqrt(float64(r.Dx()*r.Dy())/EXTRACT_SAMPLES))
+	var samples []labColor
+	for y := float64(r.Min.Y); y < float64(r.Max.Y); y += step {
+		for x := float64(r.Min.X); x < float64(r.Max.X); x += step {
+			c := img.At(int(x), int(y))
+			if _, _, _, a := c.RGBA(); a < 0x8000 {
+				continue
+			}
+			samples = append(samples, labOf(c))
+		}
+	}
+	if len(samples) < k {
+		return nil, fmt.Errorf("image has %d opaque samples, fewer than %d colors", len(samples), k)
+	}
+
+	centers := kMeans(samples, k, rand.New(rand.NewSource(seed))) //nolint:gosec
+
+	// Order from the darkest, always going to the nearest remaining color.
+	sort.Slice(centers, func(i, j int) bool { return centers[i][0] < centers[j][0] })
+	ordered := []labColor{centers[0]}
+	remaining := centers[1:]
+	for len(remaining) > 0 {
+		last := ordered[len(ordered)-1]
+		nearest := 0
+		for n := range remaining {
+			if last.dist2(remaining[n]) < last.dist2(remaining[nearest]) {
+				nearest = n
+			}
+		}
+		ordered = append(ordered, remaining[nearest])
+		remaining = append(remaining[:nearest], remaining[nearest+1:]...)
+	}
+
+	// Space the stops by the distance between the colors.
+	dists := []float64{0}
+	for n := 1; n < len(ordered); n++ {
+		dists = append(dists, dists[n-1]+math.Sqrt(ordered[n-1].dist2(ordered[n])))
+	}
+	total := dists[len(dists)-1]
+	stops := make([]ColorStop, len(ordered))
+	for n, c := range ordered {
+		pos := float64(n) / float64(len(ordered)-1)
+		if total > 0 {
+			pos = dists[n] / total
+		}
+		stops[n] = ColorStop{Pos: pos, Color: c.NRGBA()}
+	}
+	return NewPalette("", OKLab, stops...), nil
+}
+
+// kMeans returns k cluster centers of the samples, starting with k-means++.
+func kMeans(samples []labColor, k int, rng *rand.Rand) []labColor {
+	// k-means++: each center is picked with probability proportional to its
+	// squared distance from the nearest center so far.
+	centers := []labColor{samples[rng.Intn(len(samples))]}
+	nearest := make([]float64, len(samples))
+	for n, s := range samples {
+		nearest[n] = s.dist2(centers[0])
+	}
+	for len(centers) < k {
+		var sum float64
+		for _, d := range nearest {
+			sum += d
+		}
+		pick := len(samples) - 1
+		target := rng.Float64() * sum
+		for n, d := range nearest {
+			if target -= d; target < 0 {
+				pick = n
+				break
+			}
+		}
+		centers = append(centers, samples[pick])
+		for n, s := range samples {
+			nearest[n] = math.Min(nearest[n], s.dist2(samples[pick]))
+		}
+	}
+
+	assignment := make([]int, len(samples))
+	for it := 0; it < EXTRACT_ITERATIONS; it++ {
+		changed := false
+		for n, s := range samples {
+			best := 0
+			for c := range centers {
+				if s.dist2(centers[c]) < s.dist2(centers[best]) {
+					best = c
+				}
+			}
+			if best != assignment[n] || it == 0 {
+				changed = true
+			}
+			assignment[n] = best
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([]labColor, k)
+		counts := make([]int, k)
+		for n, s := range samples {
+			c := assignment[n]
+			for i := range s {
+				sums[c][i] += s[i]
+			}
+			counts[c]++
+		}
+		for c := range centers {
+			if counts[c] == 0 {
+				continue // keep empty clusters where they are
+			}
+			for i := range sums[c] {
+				centers[c][i] = sums[c][i] / float64(counts[c])
+			}
+		}
+	}
+	return centers
+}
+
+// ExtractPaletteFile extracts a palette from the PNG or JPEG at path, naming
+// it after the file.
+func ExtractPaletteFile(path string, k int, seed int64) (*Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p, err := ExtractPalette(img, k, seed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return p, nil
+}
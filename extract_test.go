@@ -0,0 +1,97 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+// testImage has columns of black, red and white, with more of each to the right.
+func testImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 10))
+	for x := 0; x < 60; x++ {
+		c := black
+		switch {
+		case x >= 30:
+			c = white
+		case x >= 10:
+			c = red
+		}
+		for y := 0; y < 10; y++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestExtractPalette(t *testing.T) {
+	p, err := ExtractPalette(testImage(), 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []color.NRGBA{black, red, white}
+	if len(p.Stops) != len(expect) {
+		t.Fatalf("Expected %d stops, got %v", len(expect), p.Stops)
+	}
+	for n, c := range expect {
+		if p.Stops[n].Color != c {
+			t.Errorf("Stop %d is %v; want %v", n, p.Stops[n].Color, c)
+		}
+	}
+	if p.Stops[0].Pos != 0 || p.Stops[2].Pos != 1 || p.Stops[1].Pos <= 0 || p.Stops[1].Pos >= 1 {
+		t.Errorf("Expected stops spread from 0 to 1, got %v", p.Stops)
+	}
+}
+
+func TestExtractPaletteDeterministic(t *testing.T) {
+	// A gradient gives k-means plenty of ways to split it.
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 4))
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 4; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(255 - x), uint8(x * y), 0xff})
+		}
+	}
+	a, err := ExtractPalette(img, 5, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ExtractPalette(img, 5, 42)
+	for n := range a.Stops {
+		if a.Stops[n] != b.Stops[n] {
+			t.Errorf("Stop %d differs with the same seed: %v != %v", n, a.Stops[n], b.Stops[n])
+		}
+	}
+
+	if _, err := ExtractPalette(img, 1, 42); err == nil {
+		t.Errorf("Expected an error for one color")
+	}
+	if _, err := ExtractPalette(image.NewNRGBA(image.Rect(0, 0, 2, 2)), 3, 42); err == nil {
+		t.Errorf("Expected an error for a transparent image")
+	}
+}
+
+func TestWritePalette(t *testing.T) {
+	expect, err := ExtractPalette(testImage(), 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"mood.json", "mood.csv"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := expect.WritePalette(path); err != nil {
+			t.Fatal(err)
+		}
+		result, err := LoadPalette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := range expect.Stops {
+			if result.Stops[n].Color != expect.Stops[n].Color {
+				t.Errorf("%s: stop %d is %v; want %v", name, n, result.Stops[n], expect.Stops[n])
+			}
+		}
+	}
+	if err := expect.WritePalette(filepath.Join(t.TempDir(), "mood.map")); err == nil {
+		t.Errorf("Expected an error writing a .map")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// BlendMode is how a layer's colors combine with the layers below.
+type BlendMode int
+
+const (
+	Normal BlendMode = iota
+	Add
+	Screen
+	Multiply
+	Overlay
+	Lighten
+)
+
+var BlendModeName = map[int]string{
+	int(Normal):   "normal",
+	int(Add):      "add",
+	int(Screen):   "screen",
+	int(Multiply): "multiply",
+	int(Overlay):  "overlay",
+	int(Lighten):  "lighten",
+}
+
+func (bm BlendMode) String() string {
+	return BlendModeName[int(bm)]
+}
+
+func (bm BlendMode) MarshalText() ([]byte, error) {
+	return []byte(bm.String()), nil
+}
+
+func (bm *BlendMode) UnmarshalText(text []byte) error {
+	for n, name := range BlendModeName {
+		if name == string(text) {
+			*bm = BlendMode(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown blend mode: %q", text)
+}
+
+// blend combines a backdrop and source channel.
+func (bm BlendMode) blend(b, s float64) float64 {
+	switch bm {
+	case Add:
+		return b + s
+	case Screen:
+		return b + s - b*s
+	case Multiply:
+		return b * s
+	case Overlay:
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	case Lighten:
+		return math.Max(b, s)
+	}
+	return s
+}
+
+// Blend returns src blended onto dst with the given opacity. Blending is in
+// linear light, compositing as in the W3C Compositing and Blending spec.
+func (bm BlendMode) Blend(src, dst LinearColor, opacity float64) LinearColor {
+	as, ab := src.A*opacity, dst.A
+	a := as + ab*(1-as)
+	if a == 0 {
+		return LinearColor{}
+	}
+	mix := func(cs, cb float64) float64 {
+		return (cs*as*(1-ab) + as*ab*bm.blend(cb, cs) + (1-as)*ab*cb) / a
+	}
+	return LinearColor{mix(src.R, dst.R), mix(src.G, dst.G), mix(src.B, dst.B), a}
+}
+
+// Layer is one calculation, or a recoloring of the layer below's results,
+// blended onto the layers below it.
+type Layer struct {
+	Params  *CalcParams `json:"params"`
+	Recolor bool        `json:"recolor,omitempty"` // color the previous layer's results with these params' ColorFunc
+	Blend   BlendMode   `json:"blend"`
+	Opacity float64     `json:"opacity"` // from 0 to 1
+}
+
+// UnmarshalJSON reads a layer whose params may instead name a preset.
+// Opacity defaults to 1.
+func (l *Layer) UnmarshalJSON(data []byte) error {
+	type layer Layer // without the methods
+	v := struct {
+		layer
+		Preset string `json:"preset,omitempty"`
+	}{layer: layer{Opacity: 1}}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Preset != "" {
+		params, ok := Presets[v.Preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q", v.Preset)
+		}
+		v.Params = params
+	}
+	if v.Params == nil {
+		return fmt.Errorf("layer needs params or a preset")
+	}
+	*l = Layer(v.layer)
+	return nil
+}
+
+// Layers is a stack of layers from the bottom up.
+type Layers []Layer
+
+// Colors calculates and colors each layer and blends them together from the
+// bottom up. Every layer must have the same view and image size as p, so
+// their pixels line up.
+func (ls Layers) Colors(p *plane.Plane) (ColorResults, error) {
+	composite := make(ColorResults)
+	var histogram CalcResults
+	for n, l := range ls {
+		if lp := l.Params.Plane; !samePlane(lp, p) {
+			return nil, fmt.Errorf("layer %d is %v at %dx%d, not %v at %dx%d",
+				n, lp.View(), lp.ImageWidth(), lp.ImageHeight(), p.View(), p.ImageWidth(), p.ImageHeight())
+		}
+		switch {
+		case l.Recolor && histogram == nil:
+			return nil, fmt.Errorf("layer %d recolors but there's no layer below", n)
+		case !l.Recolor:
+			fmt.Printf("Layer %d: calculating\n", n)
+			histogram, _ = l.Params.Histogram()
+		}
+
+		for xy, src := range l.Params.Colors(histogram) {
+			composite[xy] = l.Blend.Blend(src, composite[xy], l.Opacity)
+		}
+	}
+	return composite, nil
+}
+
+// Paint colors the layers and paints them over black in the plane's image,
+// unless it has a transparent background, dithered when quantized to 8 bits.
+func (ls Layers) Paint(p *plane.Plane, dither Dither) error {
+	colors, err := ls.Colors(p)
+	if err != nil {
+		return err
+	}
+	if !p.IsTransparent() {
+		for xy, c := range colors {
+			colors[xy] = c.Over(Black)
+		}
+	}
+	paintColors(p, colors, 1, dither, nil)
+	return nil
+}
+
+// ReadLayers reads a layers file: a JSON list of layers, each with params as
+// in a scene file or the name of a preset.
+func ReadLayers(path string) (Layers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ls Layers
+	if err := json.Unmarshal(data, &ls); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(ls) == 0 {
+		return nil, fmt.Errorf("%s: no layers", path)
+	}
+	return ls, nil
+}
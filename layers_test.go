@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func closeColor(a, b LinearColor) bool {
+	const eps = 1e-9
+	return math.Abs(a.R-b.R) < eps && math.Abs(a.G-b.G) < eps && math.Abs(a.B-b.B) < eps && math.Abs(a.A-b.A) < eps
+}
+
+func TestBlendModes(t *testing.T) {
+	dst := LinearColor{0.25, 0.5, 0.75, 1}
+	src := LinearColor{0.5, 0.5, 0.5, 1}
+	tests := []struct {
+		mode   BlendMode
+		expect LinearColor
+	}{
+		{Normal, src},
+		{Add, LinearColor{0.75, 1, 1.25, 1}},
+		{Screen, LinearColor{0.625, 0.75, 0.875, 1}},
+		{Multiply, LinearColor{0.125, 0.25, 0.375, 1}},
+		{Overlay, LinearColor{0.25, 0.5, 0.75, 1}},
+		{Lighten, LinearColor{0.5, 0.5, 0.75, 1}},
+	}
+	for _, test := range tests {
+		if result := test.mode.Blend(src, dst, 1); !closeColor(result, test.expect) {
+			t.Errorf("%v: Blend(%v, %v) = %v; want %v", test.mode, src, dst, result, test.expect)
+		}
+	}
+}
+
+func TestBlendOpacity(t *testing.T) {
+	dst := LinearColor{0.2, 0.4, 0.6, 1}
+	src := LinearColor{1, 1, 1, 1}
+	for n := range BlendModeName {
+		mode := BlendMode(n)
+		if result := mode.Blend(src, dst, 0); !closeColor(result, dst) {
+			t.Errorf("%v: opacity 0 gave %v; want %v", mode, result, dst)
+		}
+	}
+	if result, expect := Normal.Blend(src, dst, 0.5), dst.Mix(src, 0.5); !closeColor(result, expect) {
+		t.Errorf("Half opacity gave %v; want %v", result, expect)
+	}
+}
+
+func TestBlendOntoTransparent(t *testing.T) {
+	// Blending onto nothing is just the source, whatever the mode.
+	src := LinearColor{0.3, 0.6, 0.9, 1}
+	for n := range BlendModeName {
+		mode := BlendMode(n)
+		if result := mode.Blend(src, LinearColor{}, 1); !closeColor(result, src) {
+			t.Errorf("%v: Blend onto transparent = %v; want %v", mode, result, src)
+		}
+	}
+}
+
+func TestLayersRecolor(t *testing.T) {
+	params := CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(4, 4), 8),
+		Style:      Julia,
+		ZF:         zf_mandelbrot,
+		C:          complex(0.285, 0.01),
+		Iterations: 32,
+		Limit:      2,
+		CF:         cf_escaped_clip_value,
+		CFP:        ColorFuncParams{Clip: 16},
+	}
+	recolor := params
+	recolor.CF = cf_luma_clip_value
+
+	layers := Layers{
+		{Params: &params, Blend: Normal, Opacity: 1},
+		{Params: &recolor, Recolor: true, Blend: Normal, Opacity: 1},
+	}
+	colors, err := layers.Colors(params.Plane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := recolor.Colors(params.CalculateParallel())
+	if len(colors) != len(expect) {
+		t.Fatalf("Expected %d colors, got %d", len(expect), len(colors))
+	}
+	for xy, c := range expect {
+		if !closeColor(colors[xy], c) {
+			t.Fatalf("At %v got %v; want %v", xy, colors[xy], c)
+		}
+	}
+
+	// Recoloring needs a layer below.
+	if _, err := layers[1:].Colors(params.Plane); err == nil {
+		t.Error("Expected an error recoloring without a layer below")
+	}
+	// Layers must all be the same size.
+	if _, err := layers.Colors(plane.NewPlane(complex(0, 0), complex(4, 4), 16)); err == nil {
+		t.Error("Expected an error for a different size layer")
+	}
+	// And cover the same view, so their pixels line up.
+	shifted := params
+	shifted.Plane = plane.NewPlane(params.Plane.Origin()+0.5, params.Plane.Size(), params.Plane.ImageHeight())
+	if shifted.Plane.ImageWidth() != params.Plane.ImageWidth() {
+		t.Fatalf("Expected a shifted plane the same size")
+	}
+	if _, err := (Layers{layers[0], {Params: &shifted, Blend: Normal, Opacity: 1}}).Colors(params.Plane); err == nil {
+		t.Error("Expected an error for a layer with a different view")
+	}
+}
+
+func TestLayerUnmarshalJSON(t *testing.T) {
+	var layer Layer
+	if err := json.Unmarshal([]byte(`{"preset": "coldwave2", "blend": "screen"}`), &layer); err != nil {
+		t.Fatal(err)
+	}
+	if layer.Params != Presets["coldwave2"] || layer.Blend != Screen || layer.Opacity != 1 {
+		t.Errorf("Unexpected layer %+v", layer)
+	}
+
+	for _, data := range []string{`{"blend": "normal"}`, `{"preset": "nope"}`, `{"preset": "coldwave2", "blend": "dodge"}`} {
+		if err := json.Unmarshal([]byte(data), &layer); err == nil {
+			t.Errorf("Expected an error unmarshaling %s", data)
+		}
+	}
+}
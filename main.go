@@ -1,8 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"math"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/brainsik/bae/plane"
 )
@@ -10,18 +14,65 @@ import (
 const (
 	HEIGHT = 800
 	ASPECT = 1.6
+	PASSES = 4 // progressive refinement passes
 )
 
 func main() {
 	log.SetFlags(log.Lshortfile)
 
-	params := coldwave2
-	params.ColorImage()
-	params.Plane.WritePNG("image.png")
+	// The command defaults to render so flags can be given straight away.
+	cmd, args := "render", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "render":
+		err = cmdRender(args)
+	case "merge":
+		err = cmdMerge(args)
+	case "color":
+		err = cmdColor(args)
+	case "reframe":
+		err = cmdReframe(args)
+	case "scene":
+		err = cmdScene(args)
+	case "palettes":
+		err = cmdPalettes(args)
+	case "extract":
+		err = cmdExtract(args)
+	case "layers":
+		err = cmdLayers(args)
+	case "cycle":
+		err = cmdCycle(args)
+	default:
+		err = fmt.Errorf("unknown command %q: use render, merge, color, reframe, scene, palettes, extract, layers or cycle", cmd)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Presets are the CalcParams below by name.
+var Presets = map[string]*CalcParams{
+	"klein":            klein,
+	"klein2_allpts":    klein2_allpts,
+	"coldwave1":        coldwave1,
+	"coldwave1_allpts": coldwave1_allpts,
+	"coldwave2":        coldwave2,
+	"coldwave2_budget": coldwave2_budget,
+	"coldwave2_julia":  coldwave2_julia,
+	"coldwave2_fire":   coldwave2_fire,
+	"julia_classic":    julia_classic,
+	"burning_ship":     burning_ship,
+	"mandelbrot":       mandelbrot,
+	"nebulabrot":       nebulabrot,
+	"klein_domain":     klein_domain,
 }
 
 // Single orbit attractor.
-var klein = NewCalcParams(CalcParams{ //nolint:unused
+var klein = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.1, -0.54), complex(1.6*ASPECT, 1.6), HEIGHT).WithInverted(),
 
 	Style:      Attractor,
@@ -38,7 +89,7 @@ var klein = NewCalcParams(CalcParams{ //nolint:unused
 })
 
 // Multi-orbit map.
-var klein2_allpts = NewCalcParams(CalcParams{ //nolint:unused
+var klein2_allpts = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.2, -1), complex(2.2*ASPECT, 2.2), HEIGHT),
 
 	Style: Attractor,
@@ -47,7 +98,7 @@ var klein2_allpts = NewCalcParams(CalcParams{ //nolint:unused
 }.NewAllPoints(128, cf_luma_clip_percent_max, ColorFuncParams{Clip: 8, Gamma: 2.0}))
 
 // Multi-orbit map.
-var coldwave1 = NewCalcParams(CalcParams{ //nolint:unused
+var coldwave1 = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.19, 0.19), complex(0.8*ASPECT, 0.8), HEIGHT),
 
 	Style:      Attractor,
@@ -64,11 +115,11 @@ var coldwave1 = NewCalcParams(CalcParams{ //nolint:unused
 })
 
 // Multi-orbit map.
-var coldwave1_allpts = NewCalcParams(coldwave1.NewAllPoints( //nolint:unused
+var coldwave1_allpts = NewCalcParams(coldwave1.NewAllPoints(
 	16, cf_luma_clip_value, ColorFuncParams{Clip: math.Pow(2, 8)}))
 
 // Single orbit attractor.
-var coldwave2 = NewCalcParams(CalcParams{ //nolint:unused
+var coldwave2 = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.22, -0.175), complex(3.75*ASPECT, 3.75), HEIGHT),
 
 	Style:      Attractor,
@@ -84,7 +135,40 @@ var coldwave2 = NewCalcParams(CalcParams{ //nolint:unused
 	CFP: ColorFuncParams{Clip: 10},
 })
 
-var coldwave2_julia = NewCalcParams(CalcParams{ //nolint:unused
+// Single orbit attractor, sampled from random points for a fixed time.
+var coldwave2_budget = NewCalcParams(CalcParams{
+	Plane: plane.NewPlane(complex(-0.22, -0.175), complex(3.75*ASPECT, 3.75), HEIGHT),
+
+	Style:      Attractor,
+	ZF:         zf_klein,
+	C:          complex(-0.1278, 0.0),
+	Iterations: 4096,
+
+	CalcArea: plane.PlaneView{Min: complex(-0.5, -0.255), Max: complex(-0.5, 0.505)},
+	Budget:   time.Minute,
+
+	CF:  cf_luma_clip_percent_max,
+	CFP: ColorFuncParams{Clip: 10},
+})
+
+// Single orbit attractor with a palette.
+var coldwave2_fire = NewCalcParams(CalcParams{
+	Plane: plane.NewPlane(complex(-0.22, -0.175), complex(3.75*ASPECT, 3.75), HEIGHT),
+
+	Style:      Attractor,
+	ZF:         zf_klein,
+	C:          complex(-0.1278, 0.0),
+	Iterations: 4096,
+
+	CalcArea: plane.PlaneView{Min: complex(-0.5, -0.255), Max: complex(-0.5, 0.505)},
+	RPoints:  1,
+	IPoints:  3080,
+
+	CF:  cf_palette_clip_percent_max,
+	CFP: ColorFuncParams{Clip: 10, Palette: pal_fire},
+})
+
+var coldwave2_julia = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.22, -0.175), complex(3.75*ASPECT, 3.75), HEIGHT),
 
 	Style:      Julia,
@@ -96,7 +180,7 @@ var coldwave2_julia = NewCalcParams(CalcParams{ //nolint:unused
 	CFP: ColorFuncParams{Clip: 50},
 })
 
-var julia_classic = NewCalcParams(CalcParams{ //nolint:unused
+var julia_classic = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(0, 0), complex(4*ASPECT, 4), HEIGHT),
 
 	Style:      Julia,
@@ -104,11 +188,13 @@ var julia_classic = NewCalcParams(CalcParams{ //nolint:unused
 	C:          complex(0.285, 0.01),
 	Iterations: 493,
 
+	Subdivide: true,
+
 	CF:  cf_escaped_clip_percent_max,
 	CFP: ColorFuncParams{Clip: 50},
 })
 
-var burning_ship = NewCalcParams(CalcParams{ //nolint:unused
+var burning_ship = NewCalcParams(CalcParams{
 	// Plane: plane.NewPlane(complex(1.75, 0.038), complex(0.145, 0.145*ASPECT_INV), HEIGHT),
 	Plane: plane.NewPlane(complex(-1.765, -0.035), complex(0.15*ASPECT, 0.15), HEIGHT).WithInverted(),
 
@@ -120,13 +206,48 @@ var burning_ship = NewCalcParams(CalcParams{ //nolint:unused
 	CFP: ColorFuncParams{Clip: 400},
 })
 
-var mandelbrot = NewCalcParams(CalcParams{ //nolint:unused
+var mandelbrot = NewCalcParams(CalcParams{
 	Plane: plane.NewPlane(complex(-0.5, 0), complex(4*ASPECT, 4), HEIGHT),
 
 	Style:      Mandelbrot,
 	ZF:         zf_mandelbrot,
 	Iterations: 256,
 
+	Subdivide: true,
+
 	CF:  cf_escaped_clip_percent_avg,
 	CFP: ColorFuncParams{Clip: 400},
 })
+
+var nebulabrot = NewCalcParams(CalcParams{
+	Plane: plane.NewPlane(complex(-0.5, 0), complex(3*ASPECT, 3), HEIGHT).WithInverted(),
+
+	Style:      Buddhabrot,
+	ZF:         zf_mandelbrot,
+	Iterations: 5000,
+	Limit:      2,
+
+	CalcArea: plane.PlaneView{Min: complex(-2, -1.5), Max: complex(1, 1.5)},
+	RPoints:  1000,
+	IPoints:  1000,
+
+	CH: ch_nebulabrot,
+
+	CF: cf_channels_rgb,
+	CFP: ColorFuncParams{Channels: [NUM_CHANNELS]ChannelParams{
+		{Clip: 50, Gamma: 2.2}, {Clip: 50, Gamma: 2.2}, {Clip: 50, Gamma: 2.2},
+	}},
+})
+
+// What one step of the klein map does to the plane, before iterating it.
+var klein_domain = NewCalcParams(CalcParams{
+	Plane: plane.NewPlane(complex(0, 0), complex(3*ASPECT, 3), HEIGHT),
+
+	Style:      Domain,
+	ZF:         zf_klein,
+	C:          complex(-0.1278, 0.0),
+	Iterations: 1,
+
+	CF:  cf_domain_rings,
+	CFP: ColorFuncParams{Grid: 0.25},
+})
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// Mask restricts a calculation to a region of the image.
+type Mask interface {
+	Contains(xy plane.ImagePoint) bool
+	Bounds() image.Rectangle // smallest rectangle holding the region
+}
+
+// RectMask is a rectangular region of the image.
+type RectMask image.Rectangle
+
+func (m RectMask) Contains(xy plane.ImagePoint) bool {
+	return image.Pt(xy.X, xy.Y).In(image.Rectangle(m))
+}
+
+func (m RectMask) Bounds() image.Rectangle {
+	return image.Rectangle(m)
+}
+
+func (m RectMask) String() string {
+	return fmt.Sprintf("RectMask%v", image.Rectangle(m))
+}
+
+// ImageMask is the region of an image which is brighter than 50% gray.
+type ImageMask struct {
+	img    image.Image
+	bounds image.Rectangle
+}
+
+// NewImageMask returns the mask for img.
+func NewImageMask(img image.Image) *ImageMask {
+	m := &ImageMask{img: img}
+	r := img.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if m.Contains(plane.ImagePoint{X: x, Y: y}) {
+				m.bounds = m.bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return m
+}
+
+func (m *ImageMask) Contains(xy plane.ImagePoint) bool {
+	if !image.Pt(xy.X, xy.Y).In(m.img.Bounds()) {
+		return false
+	}
+	gray := color.Gray16Model.Convert(m.img.At(xy.X, xy.Y)).(color.Gray16)
+	return gray.Y >= 0x8000
+}
+
+func (m *ImageMask) Bounds() image.Rectangle {
+	return m.bounds
+}
+
+func (m *ImageMask) String() string {
+	return fmt.Sprintf("ImageMask%v", m.bounds)
+}
+
+// ParseMask returns the mask described by s, which is either a pixel
+// rectangle "x0,y0,x1,y1" or the path to a PNG.
+func ParseMask(s string) (Mask, error) {
+	if parts := strings.Split(s, ","); len(parts) == 4 {
+		var coords [4]int
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("bad mask rectangle %q: %w", s, err)
+			}
+			coords[i] = n
+		}
+		return RectMask(image.Rect(coords[0], coords[1], coords[2], coords[3])), nil
+	}
+
+	f, err := os.Open(s)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s, err)
+	}
+	return NewImageMask(img), nil
+}
+
+// inMask returns whether xy is in the CalcParams mask, if there is one.
+func (cp *CalcParams) inMask(xy plane.ImagePoint) bool {
+	return cp.Mask == nil || cp.Mask.Contains(xy)
+}
+
+// ClearMask sets the image inside the mask to the background, so an image
+// being re-rendered over doesn't show through where nothing is painted.
+func (cp *CalcParams) ClearMask() {
+	if cp.Mask == nil {
+		return
+	}
+	bg := cp.Plane.Background()
+	r := cp.Mask.Bounds().Intersect(cp.Plane.Image().Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if cp.Mask.Contains(plane.ImagePoint{X: x, Y: y}) {
+				cp.Plane.SetXYColor(x, y, bg)
+			}
+		}
+	}
+}
+
+// Composite replaces the results inside the mask with those from src.
+func (cr CalcResults) Composite(src CalcResults, mask Mask) {
+	for xy := range cr {
+		if mask.Contains(xy) {
+			delete(cr, xy)
+		}
+	}
+	for xy, r := range src {
+		if mask.Contains(xy) {
+			cr[xy] = r
+		}
+	}
+}
+
+// Composite replaces the archive's results inside the mask with those from
+// orbits calculated with params. They must land on the same pixels as the
+// archive's, but can have their own zfunc, iterations and parameters. The
+// params are recorded with the composites and the orbits added to Composited.
+func (a *Archive) Composite(params *CalcParams, results CalcResults, orbits int, mask Mask) error {
+	if err := a.Params.sameFrame(params); err != nil {
+		return err
+	}
+	a.Results.Composite(results, mask)
+	a.Composited += orbits
+	cp := *params
+	a.Composites = append(a.Composites, ArchiveComposite{Mask: fmt.Sprint(mask), Params: &cp, Orbits: orbits})
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestParseMaskRect(t *testing.T) {
+	result, err := ParseMask("1, 2, 5,6")
+	if err != nil {
+		t.Fatalf("ParseMask Error: %v", err)
+	}
+	expect := RectMask(image.Rect(1, 2, 5, 6))
+	if result != expect {
+		t.Error(result, expect)
+	}
+	if !result.Contains(plane.ImagePoint{X: 1, Y: 2}) || result.Contains(plane.ImagePoint{X: 5, Y: 6}) {
+		t.Errorf("Expected mask to contain its min point and not its max point")
+	}
+}
+
+func TestImageMask(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	img.SetGray(1, 2, color.Gray{0xff})
+	img.SetGray(2, 2, color.Gray{0x7f})
+	mask := NewImageMask(img)
+
+	if !mask.Contains(plane.ImagePoint{X: 1, Y: 2}) {
+		t.Errorf("Expected white pixel to be in the mask")
+	}
+	if mask.Contains(plane.ImagePoint{X: 2, Y: 2}) {
+		t.Errorf("Expected dark gray pixel to be outside the mask")
+	}
+	if expect := image.Rect(1, 2, 2, 3); mask.Bounds() != expect {
+		t.Error(mask.Bounds(), expect)
+	}
+}
+
+func TestMakeImageProblemSetMask(t *testing.T) {
+	params := CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(2, 2), 10),
+		Mask:  RectMask(image.Rect(2, 3, 5, 5)),
+	}
+	result := params.MakeImageProblemSet()
+	if len(result) != 6 {
+		t.Fatalf("len(result) = %d; want 6", len(result))
+	}
+	for _, pt := range result {
+		if !params.Mask.Contains(pt.XY) {
+			t.Errorf("Point %v is outside the mask", pt)
+		}
+	}
+}
+
+func TestCalcResultsComposite(t *testing.T) {
+	inside := plane.ImagePoint{X: 1, Y: 1}
+	outside := plane.ImagePoint{X: 5, Y: 5}
+	dst := CalcResults{
+		inside:                       &CalcResult{Val: 1},
+		outside:                      &CalcResult{Val: 2},
+		plane.ImagePoint{X: 0, Y: 0}: &CalcResult{Val: 3},
+	}
+	src := CalcResults{
+		inside:  &CalcResult{Val: 10},
+		outside: &CalcResult{Val: 20},
+	}
+	dst.Composite(src, RectMask(image.Rect(0, 0, 2, 2)))
+
+	if len(dst) != 2 {
+		t.Errorf("len(dst) = %d; want 2", len(dst))
+	}
+	if dst[inside].Val != 10 {
+		t.Error(dst[inside].Val, 10)
+	}
+	if dst[outside].Val != 2 {
+		t.Error(dst[outside].Val, 2)
+	}
+}
+
+func TestPaintMaskedOver(t *testing.T) {
+	params := CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(4, 4), 8),
+		Mask:  RectMask(image.Rect(0, 0, 6, 6)),
+	}
+	// The image being re-rendered over is white.
+	img := params.Plane.Image()
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	params.ClearMask()
+
+	// A block from a progressive pass crosses the mask edge.
+	colors := ColorResults{plane.ImagePoint{X: 4, Y: 4}: LinearColor{0, 0, 1, 1}}
+	paintColors(params.Plane, colors, 4, NoDither, params.Mask)
+
+	testCases := []struct {
+		x, y   int
+		expect color.Color
+	}{
+		{0, 0, color.Black},                   // cleared, nothing painted
+		{5, 5, color.NRGBA{0, 0, 0xff, 0xff}}, // painted inside the mask
+		{6, 5, color.White},                   // block clipped at the mask
+		{7, 7, color.White},
+	}
+	for _, tc := range testCases {
+		r, g, b, a := img.At(tc.x, tc.y).RGBA()
+		er, eg, eb, ea := tc.expect.RGBA()
+		if r != er || g != eg || b != eb || a != ea {
+			t.Errorf("(%d, %d) is %v; want %v", tc.x, tc.y, img.At(tc.x, tc.y), tc.expect)
+		}
+	}
+}
+
+func TestArchiveComposite(t *testing.T) {
+	mask := RectMask(image.Rect(0, 0, 2, 3))
+	results := CalcResults{plane.ImagePoint{X: 1, Y: 2}: &CalcResult{Val: 100}}
+
+	a := testArchive()
+	params := NewCalcParams(*a.Params)
+	params.Plane = plane.NewPlane(complex(0.5, 0), complex(4, 2), 16).WithInverted()
+	if err := a.Composite(params, results, 4, mask); err == nil {
+		t.Errorf("Expected compositing another plane's results to be refused")
+	}
+
+	params = NewCalcParams(*a.Params)
+	params.ZF = zf_klein2
+	params.Iterations *= 2
+	if err := a.Composite(params, results, 4, mask); err != nil {
+		t.Fatalf("Composite Error: %v", err)
+	}
+	if err := a.Composite(params, results, 3, mask); err != nil {
+		t.Fatalf("Composite Error: %v", err)
+	}
+	if a.Results[plane.ImagePoint{X: 1, Y: 2}].Val != 100 || a.Orbits != 8 || a.Composited != 7 {
+		t.Errorf("Expected the masked result and 8+7 orbits, got %v and %d+%d",
+			a.Results[plane.ImagePoint{X: 1, Y: 2}], a.Orbits, a.Composited)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	result, err := DecodeArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Composited != 7 {
+		t.Errorf("Composited = %d; want 7", result.Composited)
+	}
+	if len(result.Composites) != 2 {
+		t.Fatalf("Expected 2 composites, got %d", len(result.Composites))
+	}
+	c := result.Composites[1]
+	if c.Mask != mask.String() || c.Orbits != 3 || c.Params.ZF.Name != zf_klein2.Name || c.Params.Iterations != params.Iterations {
+		t.Errorf("Expected the composite's mask, orbits and params, got %v %d %v %d",
+			c.Mask, c.Orbits, c.Params.ZF.Name, c.Params.Iterations)
+	}
+	if result.Params.ZF.Name != a.Params.ZF.Name {
+		t.Errorf("Expected the archive's own zfunc %v, got %v", a.Params.ZF.Name, result.Params.ZF.Name)
+	}
+	if err := testArchive().Merge(result); err == nil {
+		t.Errorf("Expected merging a composited archive to be refused")
+	}
+}
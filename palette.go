@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Interpolation is the color space palettes blend between stops in.
+type Interpolation int
+
+const (
+	OKLab     Interpolation = iota // perceptually even lightness and hue
+	OKLCh                          // OKLab in polar form, blending around the hue wheel
+	LinearRGB                      // physically mixed light
+)
+
+var InterpolationName = map[int]string{
+	int(OKLab):     "oklab",
+	int(OKLCh):     "oklch",
+	int(LinearRGB): "linear",
+}
+
+func (in Interpolation) String() string {
+	return InterpolationName[int(in)]
+}
+
+func (in Interpolation) MarshalText() ([]byte, error) {
+	return []byte(in.String()), nil
+}
+
+func (in *Interpolation) UnmarshalText(text []byte) error {
+	for n, name := range InterpolationName {
+		if name == string(text) {
+			*in = Interpolation(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown interpolation: %q", text)
+}
+
+// ColorStop is a palette color at a position from 0 to 1.
+type ColorStop struct {
+	Pos   float64
+	Color color.NRGBA
+}
+
+type colorStopJSON struct {
+	Pos   float64 `json:"pos"`
+	Color string  `json:"color"` // #rrggbb or #rrggbbaa
+}
+
+func (cs ColorStop) MarshalJSON() ([]byte, error) {
+	c := cs.Color
+	hex := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 0xff {
+		hex += fmt.Sprintf("%02x", c.A)
+	}
+	return json.Marshal(colorStopJSON{Pos: cs.Pos, Color: hex})
+}
+
+func (cs *ColorStop) UnmarshalJSON(data []byte) error {
+	var v colorStopJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c, err := ParseHexColor(v.Color)
+	if err != nil {
+		return err
+	}
+	*cs = ColorStop{Pos: v.Pos, Color: c}
+	return nil
+}
+
+// ParseHexColor parses a #rrggbb or #rrggbbaa color.
+func ParseHexColor(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 0xff}
+	hex := strings.TrimPrefix(s, "#")
+	var err error
+	switch len(hex) {
+	case 6:
+		_, err = fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 8:
+		_, err = fmt.Sscanf(hex, "%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("wrong length")
+	}
+	if err != nil {
+		return c, fmt.Errorf("bad color %q (want #rrggbb): %w", s, err)
+	}
+	return c, nil
+}
+
+// Palette is a gradient through color stops. Cyclic palettes repeat every
+// Period (default 1) after shifting by Offset, blending from the last stop
+// back around to the first. Other palettes hold the end colors outside 0-1.
+type Palette struct {
+	Name   string        `json:"name,omitempty"`
+	Stops  []ColorStop   `json:"stops"`
+	Interp Interpolation `json:"interp"`
+	Cyclic bool          `json:"cyclic,omitempty"`
+	Offset float64       `json:"offset,omitempty"`
+	Period float64       `json:"period,omitempty"`
+}
+
+// NewPalette returns a palette through the stops, sorted by position.
+func NewPalette(name string, interp Interpolation, stops ...ColorStop) *Palette {
+	p := &Palette{Name: name, Stops: stops, Interp: interp}
+	p.sortStops()
+	return p
+}
+
+func (p *Palette) sortStops() {
+	sort.SliceStable(p.Stops, func(i, j int) bool { return p.Stops[i].Pos < p.Stops[j].Pos })
+}
+
+func (p *Palette) UnmarshalJSON(data []byte) error {
+	type palette Palette // without the methods
+	var v palette
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Stops) == 0 {
+		return fmt.Errorf("palette %q has no stops", v.Name)
+	}
+	*p = Palette(v)
+	p.sortStops()
+	return nil
+}
+
+func (p *Palette) String() string {
+	return fmt.Sprintf("Palette{%s, %d stops, %v, cyclic:%v}", p.Name, len(p.Stops), p.Interp, p.Cyclic)
+}
+
+// WithCycle returns a copy of the palette repeating every period after offset.
+func (p *Palette) WithCycle(offset, period float64) *Palette {
+	new := *p
+	new.Cyclic, new.Offset, new.Period = true, offset, period
+	return &new
+}
+
+// At returns the palette's color at t.
+func (p *Palette) At(t float64) LinearColor {
+	stops := p.Stops
+	switch {
+	case len(stops) == 0:
+		return Black
+	case len(stops) == 1:
+		return LinearOf(stops[0].Color)
+	}
+
+	if p.Cyclic {
+		period := p.Period
+		if period <= 0 {
+			period = 1
+		}
+		t = (t + p.Offset) / period
+		t -= math.Floor(t)
+	} else {
+		t = math.Max(0, math.Min(t, 1))
+	}
+
+	// Find the stops either side of t. Cyclic palettes wrap around between
+	// the last and first stop.
+	first, last := stops[0], stops[len(stops)-1]
+	var a, b ColorStop
+	switch n := sort.Search(len(stops), func(i int) bool { return stops[i].Pos > t }); {
+	case n == 0 && p.Cyclic:
+		a, b = ColorStop{last.Pos - 1, last.Color}, first
+	case n == 0:
+		return LinearOf(first.Color)
+	case n == len(stops) && p.Cyclic:
+		a, b = last, ColorStop{first.Pos + 1, first.Color}
+	case n == len(stops):
+		return LinearOf(last.Color)
+	default:
+		a, b = stops[n-1], stops[n]
+	}
+	if b.Pos <= a.Pos {
+		return LinearOf(b.Color)
+	}
+	return p.Interp.mix(LinearOf(a.Color), LinearOf(b.Color), (t-a.Pos)/(b.Pos-a.Pos))
+}
+
+// mix blends from one color to another by f in the interpolation's color space.
+func (in Interpolation) mix(from, to LinearColor, f float64) LinearColor {
+	if in == LinearRGB {
+		return from.Mix(to, f)
+	}
+
+	lerp := func(x, y float64) float64 { return x + f*(y-x) }
+	alpha := lerp(from.A, to.A)
+	al, aa, ab := LinearToOKLab(from.R, from.G, from.B)
+	bl, ba, bb := LinearToOKLab(to.R, to.G, to.B)
+	if in == OKLCh {
+		ac, ah := math.Hypot(aa, ab), math.Atan2(ab, aa)
+		bc, bh := math.Hypot(ba, bb), math.Atan2(bb, ba)
+		// Grays have no hue so take the other stop's.
+		const gray = 1e-4
+		if ac < gray {
+			ah = bh
+		}
+		if bc < gray {
+			bh = ah
+		}
+		// Take the short way around the hue wheel.
+		dh := math.Remainder(bh-ah, 2*math.Pi)
+		c, h := lerp(ac, bc), ah+f*dh
+		r, g, b := OKLabToLinear(lerp(al, bl), c*math.Cos(h), c*math.Sin(h))
+		return LinearColor{r, g, b, alpha}
+	}
+	r, g, b := OKLabToLinear(lerp(al, bl), lerp(aa, ba), lerp(ab, bb))
+	return LinearColor{r, g, b, alpha}
+}
+
+// LinearToOKLab converts linear sRGB to OKLab (https://bottosson.github.io/posts/oklab/).
+func LinearToOKLab(r, g, b float64) (L, A, B float64) {
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s
+}
+
+// OKLabToLinear converts OKLab to linear sRGB, which may be out of gamut.
+func OKLabToLinear(L, A, B float64) (r, g, b float64) {
+	l := L + 0.3963377774*A + 0.2158037573*B
+	m := L - 0.1055613458*A - 0.0638541728*B
+	s := L - 0.0894841775*A - 1.2914855480*B
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return 4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+}
+
+// Built in palettes.
+var (
+	pal_gray = NewPalette("gray", OKLab,
+		ColorStop{0, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+		ColorStop{1, color.NRGBA{0xff, 0xff, 0xff, 0xff}})
+
+	pal_fire = NewPalette("fire", OKLab,
+		ColorStop{0, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+		ColorStop{0.35, color.NRGBA{0x8b, 0x10, 0x10, 0xff}},
+		ColorStop{0.7, color.NRGBA{0xff, 0x8c, 0x00, 0xff}},
+		ColorStop{1, color.NRGBA{0xff, 0xf8, 0xdc, 0xff}})
+
+	pal_coldwave = NewPalette("coldwave", OKLab,
+		ColorStop{0, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+		ColorStop{0.5, color.NRGBA{0x1e, 0x3a, 0x8a, 0xff}},
+		ColorStop{0.8, color.NRGBA{0x6f, 0xc3, 0xdf, 0xff}},
+		ColorStop{1, color.NRGBA{0xf0, 0xff, 0xff, 0xff}})
+
+	pal_rainbow = NewPalette("rainbow", OKLCh,
+		ColorStop{0, color.NRGBA{0xe6, 0x3c, 0x3c, 0xff}},
+		ColorStop{1.0 / 3, color.NRGBA{0x3c, 0xb4, 0x4b, 0xff}},
+		ColorStop{2.0 / 3, color.NRGBA{0x43, 0x63, 0xd8, 0xff}}).WithCycle(0, 1)
+)
+
+// Palettes are the built in palettes by name.
+var Palettes = map[string]*Palette{
+	pal_gray.Name:     pal_gray,
+	pal_fire.Name:     pal_fire,
+	pal_coldwave.Name: pal_coldwave,
+	pal_rainbow.Name:  pal_rainbow,
+}
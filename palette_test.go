@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var (
+	black = color.NRGBA{0, 0, 0, 0xff}
+	white = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	red   = color.NRGBA{0xff, 0, 0, 0xff}
+	blue  = color.NRGBA{0, 0, 0xff, 0xff}
+)
+
+func TestPaletteEnds(t *testing.T) {
+	for _, interp := range []Interpolation{OKLab, OKLCh, LinearRGB} {
+		p := NewPalette("test", interp, ColorStop{1, white}, ColorStop{0, black})
+		testCases := []struct {
+			t      float64
+			expect color.NRGBA
+		}{
+			{-1, black}, {0, black}, {1, white}, {2, white},
+		}
+		for _, tc := range testCases {
+			if result := p.At(tc.t).NRGBA(); result != tc.expect {
+				t.Errorf("%v: At(%v) = %v; want %v", interp, tc.t, result, tc.expect)
+			}
+		}
+	}
+}
+
+func TestPaletteInterpolation(t *testing.T) {
+	testCases := []struct {
+		interp Interpolation
+		expect color.NRGBA
+	}{
+		// Halfway in OKLab is perceptual middle gray, brighter than sRGB
+		// 50% for linear light.
+		{OKLab, color.NRGBA{0x63, 0x63, 0x63, 0xff}},
+		{LinearRGB, color.NRGBA{0xbc, 0xbc, 0xbc, 0xff}},
+	}
+	for _, tc := range testCases {
+		p := NewPalette("gray", tc.interp, ColorStop{0, black}, ColorStop{1, white})
+		if result := p.At(0.5).NRGBA(); result != tc.expect {
+			t.Errorf("%v: At(0.5) = %v; want %v", tc.interp, result, tc.expect)
+		}
+	}
+}
+
+func TestPaletteOKLChKeepsChroma(t *testing.T) {
+	lab := NewPalette("lab", OKLab, ColorStop{0, red}, ColorStop{1, blue})
+	lch := NewPalette("lch", OKLCh, ColorStop{0, red}, ColorStop{1, blue})
+	chroma := func(c LinearColor) float64 {
+		_, a, b := LinearToOKLab(c.R, c.G, c.B)
+		return math.Hypot(a, b)
+	}
+	if chroma(lch.At(0.5)) <= chroma(lab.At(0.5)) {
+		t.Errorf("Expected OKLCh %v to be more saturated than OKLab %v", lch.At(0.5), lab.At(0.5))
+	}
+}
+
+func TestPaletteCyclic(t *testing.T) {
+	p := NewPalette("cycle", LinearRGB, ColorStop{0, black}, ColorStop{0.5, white}).WithCycle(0.25, 2)
+	testCases := []struct {
+		t      float64
+		expect color.NRGBA
+	}{
+		{-0.25, black},
+		{0.75, white},
+		{1.75, black},
+		{3.75, black},
+	}
+	for _, tc := range testCases {
+		if result := p.At(tc.t).NRGBA(); result != tc.expect {
+			t.Errorf("At(%v) = %v; want %v", tc.t, result, tc.expect)
+		}
+	}
+	// Past the last stop blends back around to the first.
+	if a, b := p.At(1.25).NRGBA(), p.At(2.25).NRGBA(); a != b || a == black || a == white {
+		t.Errorf("Expected wrapping between the last and first stop, got %v and %v", a, b)
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, c := range []color.NRGBA{black, white, red, blue, {0x12, 0x34, 0x56, 0xff}} {
+		l := LinearOf(c)
+		r, g, b := OKLabToLinear(LinearToOKLab(l.R, l.G, l.B))
+		if result := (LinearColor{r, g, b, l.A}).NRGBA(); result != c {
+			t.Errorf("Round trip of %v gave %v", c, result)
+		}
+	}
+}
+
+func TestPaletteJSON(t *testing.T) {
+	expect := NewPalette("test", OKLCh,
+		ColorStop{0, black}, ColorStop{0.5, color.NRGBA{0x12, 0x34, 0x56, 0x78}}, ColorStop{1, white}).WithCycle(0.1, 0.5)
+	data, err := json.Marshal(expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Palette
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != expect.String() || result.Offset != expect.Offset || result.Period != expect.Period {
+		t.Errorf("Expected %v, got %v", expect, &result)
+	}
+	for n := range expect.Stops {
+		if result.Stops[n] != expect.Stops[n] {
+			t.Errorf("Stop %d is %v; want %v", n, result.Stops[n], expect.Stops[n])
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"stops":[{"pos":0,"color":"#12345"}]}`), &result); err == nil {
+		t.Errorf("Expected an error for a bad color")
+	}
+	if err := json.Unmarshal([]byte(`{"stops":[{"pos":0,"color":"#123456"}],"interp":"hsv"}`), &result); err == nil {
+		t.Errorf("Expected an error for an unknown interpolation")
+	}
+}
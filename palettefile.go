@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PALETTE_EXTS are the palette file types LoadPalette reads.
+var PALETTE_EXTS = []string{".map", ".ggr", ".csv", ".json"}
+
+// GGR_SAMPLES is how many stops each GIMP gradient segment is sampled into.
+const GGR_SAMPLES = 16
+
+// LoadPalette reads a palette file. The type comes from the extension:
+//
+//	.map   Fractint / Fractal Extreme map, one "r g b" line per color
+//	.ggr   GIMP gradient
+//	.csv   "pos,#rrggbb" or "pos,r,g,b" stops, one per line
+//	.json  a Palette as in scene files
+//
+// Palettes without a name are named after the file.
+func LoadPalette(path string) (*Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var p *Palette
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".map":
+		p, err = ReadMapPalette(f)
+	case ".ggr":
+		p, err = ReadGGRPalette(f)
+	case ".csv":
+		p, err = ReadCSVPalette(f)
+	case ".json":
+		p = new(Palette)
+		err = json.NewDecoder(f).Decode(p)
+	default:
+		return nil, fmt.Errorf("%s: unknown palette type %q (want one of %v)", path, ext, PALETTE_EXTS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = name
+	}
+	return p, nil
+}
+
+// WritePalette writes the palette to path as .json, or as .csv stops.
+func (p *Palette) WritePalette(path string) error {
+	var data []byte
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		var err error
+		if data, err = json.MarshalIndent(p, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	case ".csv":
+		for _, stop := range p.Stops {
+			c := stop.Color
+			data = fmt.Appendf(data, "%g,#%02x%02x%02x\n", stop.Pos, c.R, c.G, c.B)
+		}
+	default:
+		return fmt.Errorf("%s: can only write .json or .csv palettes", path)
+	}
+	return os.WriteFile(path, data, 0o644) //nolint:gosec
+}
+
+// LookupPalette returns the built in palette with the given name, or else
+// loads it as a palette file.
+func LookupPalette(name string) (*Palette, error) {
+	if p, ok := Palettes[name]; ok {
+		return p, nil
+	}
+	if _, err := os.Stat(name); err != nil {
+		return nil, fmt.Errorf("unknown palette %q: not built in (%s) or a file", name, paletteNames())
+	}
+	return LoadPalette(name)
+}
+
+// ReadMapPalette reads a Fractint map: lines of red, green and blue from 0
+// to 255. Anything after the third number on a line is a comment.
+func ReadMapPalette(r io.Reader) (*Palette, error) {
+	var colors []color.NRGBA
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: want r g b", line)
+		}
+		c := color.NRGBA{A: 0xff}
+		for n, dst := range []*uint8{&c.R, &c.G, &c.B} {
+			v, err := strconv.ParseUint(fields[n], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			*dst = uint8(v)
+		}
+		colors = append(colors, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(colors) == 0 {
+		return nil, fmt.Errorf("no colors")
+	}
+
+	stops := make([]ColorStop, len(colors))
+	for n, c := range colors {
+		stops[n] = ColorStop{Pos: float64(n) / math.Max(float64(len(colors)-1), 1), Color: c}
+	}
+	return NewPalette("", OKLab, stops...), nil
+}
+
+// ReadCSVPalette reads stops of "pos,#rrggbb" or "pos,r,g,b". Blank lines
+// and lines starting with # are skipped.
+func ReadCSVPalette(r io.Reader) (*Palette, error) {
+	var stops []ColorStop
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		for n := range fields {
+			fields[n] = strings.TrimSpace(fields[n])
+		}
+		pos, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var c color.NRGBA
+		switch len(fields) {
+		case 2:
+			c, err = ParseHexColor(fields[1])
+		case 4:
+			c = color.NRGBA{A: 0xff}
+			for n, dst := range []*uint8{&c.R, &c.G, &c.B} {
+				var v uint64
+				if v, err = strconv.ParseUint(fields[n+1], 10, 8); err != nil {
+					break
+				}
+				*dst = uint8(v)
+			}
+		default:
+			err = fmt.Errorf("want pos,#rrggbb or pos,r,g,b")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		stops = append(stops, ColorStop{Pos: pos, Color: c})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stops) == 0 {
+		return nil, fmt.Errorf("no stops")
+	}
+	return NewPalette("", OKLab, stops...), nil
+}
+
+// ReadGGRPalette reads a GIMP gradient. Each segment is sampled into
+// GGR_SAMPLES stops following its blending function. Segments colored in
+// HSV are blended in RGB.
+func ReadGGRPalette(r io.Reader) (*Palette, error) {
+	scanner := bufio.NewScanner(r)
+	next := func() (string, bool) {
+		for scanner.Scan() {
+			if text := strings.TrimSpace(scanner.Text()); text != "" {
+				return text, true
+			}
+		}
+		return "", false
+	}
+
+	if header, _ := next(); header != "GIMP Gradient" {
+		return nil, fmt.Errorf("not a GIMP gradient")
+	}
+	text, _ := next()
+	var name string
+	if strings.HasPrefix(text, "Name:") {
+		name = strings.TrimSpace(strings.TrimPrefix(text, "Name:"))
+		text, _ = next()
+	}
+	num_segments, err := strconv.Atoi(text)
+	if err != nil {
+		return nil, fmt.Errorf("segment count: %w", err)
+	}
+
+	var stops []ColorStop
+	for n := 0; n < num_segments; n++ {
+		text, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("expected %d segments, got %d", num_segments, n)
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 11 {
+			return nil, fmt.Errorf("segment %d: want at least 11 values", n)
+		}
+		var v [13]float64
+		for i := 0; i < len(fields) && i < len(v); i++ {
+			if v[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("segment %d: %w", n, err)
+			}
+		}
+		left, mid, right := v[0], v[1], v[2]
+		from := [4]float64{v[3], v[4], v[5], v[6]}
+		to := [4]float64{v[7], v[8], v[9], v[10]}
+		blend := int(v[11])
+
+		for i := 0; i <= GGR_SAMPLES; i++ {
+			if i == 0 && n > 0 {
+				continue // the previous segment ended here
+			}
+			x := float64(i) / GGR_SAMPLES
+			pos := left + (right-left)*x
+			m := 0.5
+			if right > left {
+				m = (mid - left) / (right - left)
+			}
+			f := ggrFactor(blend, x, m)
+			var c [4]uint8
+			for ch := range c {
+				c[ch] = uint8(math.Round(0xff * clamp01(from[ch]+f*(to[ch]-from[ch]))))
+			}
+			stops = append(stops, ColorStop{Pos: pos, Color: color.NRGBA{c[0], c[1], c[2], c[3]}})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stops) == 0 {
+		return nil, fmt.Errorf("no segments")
+	}
+	return NewPalette(name, LinearRGB, stops...), nil
+}
+
+// ggrFactor returns how far to blend at x through a segment with its
+// midpoint at m, both from 0 to 1, using a GIMP blending function.
+func ggrFactor(blend int, x, m float64) float64 {
+	// Most blends bend x so the midpoint is at 0.5 first.
+	var linear float64
+	switch {
+	case m <= 0:
+		linear = 1
+	case m >= 1:
+		linear = 0
+	case x <= m:
+		linear = 0.5 * x / m
+	default:
+		linear = 0.5 + 0.5*(x-m)/(1-m)
+	}
+
+	switch blend {
+	case 1: // curved
+		if m <= 0 || m >= 1 {
+			return linear
+		}
+		return math.Pow(x, math.Log(0.5)/math.Log(m))
+	case 2: // sine
+		return (math.Sin(-math.Pi/2+math.Pi*linear) + 1) / 2
+	case 3: // sphere increasing
+		return math.Sqrt(1 - (linear-1)*(linear-1))
+	case 4: // sphere decreasing
+		return 1 - math.Sqrt(1-linear*linear)
+	case 5: // step
+		if x < m {
+			return 0
+		}
+		return 1
+	}
+	return linear
+}
+
+// LoadPalettes loads the palette files, and the palette files in any
+// directories, given.
+func LoadPalettes(paths ...string) ([]*Palette, error) {
+	var palettes []*Palette
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				ext := strings.ToLower(filepath.Ext(entry.Name()))
+				for _, known := range PALETTE_EXTS {
+					if ext == known && !entry.IsDir() {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+		for _, file := range files {
+			p, err := LoadPalette(file)
+			if err != nil {
+				return nil, err
+			}
+			palettes = append(palettes, p)
+		}
+	}
+	return palettes, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadMapPalette(t *testing.T) {
+	p, err := ReadMapPalette(strings.NewReader("0 0 0  black\n\n255 0 0 red\n255 255 255\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Stops) != 3 || p.Stops[1].Pos != 0.5 || p.Stops[1].Color != red {
+		t.Errorf("Expected 3 stops with red in the middle, got %v", p.Stops)
+	}
+	if _, err := ReadMapPalette(strings.NewReader("0 0\n")); err == nil {
+		t.Errorf("Expected an error for a short line")
+	}
+	if _, err := ReadMapPalette(strings.NewReader("0 0 256\n")); err == nil {
+		t.Errorf("Expected an error for a component out of range")
+	}
+}
+
+func TestReadCSVPalette(t *testing.T) {
+	p, err := ReadCSVPalette(strings.NewReader("# pos,color\n1, #ffffff\n0,0,0,0\n0.5,255,0,0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []ColorStop{{0, black}, {0.5, red}, {1, white}}
+	if len(p.Stops) != len(expect) {
+		t.Fatalf("Expected %v, got %v", expect, p.Stops)
+	}
+	for n := range expect {
+		if p.Stops[n] != expect[n] {
+			t.Errorf("Stop %d is %v; want %v", n, p.Stops[n], expect[n])
+		}
+	}
+	if _, err := ReadCSVPalette(strings.NewReader("0,1,2\n")); err == nil {
+		t.Errorf("Expected an error for three fields")
+	}
+}
+
+const testGGR = `GIMP Gradient
+Name: Test
+2
+0.000000 0.250000 0.500000 0.000000 0.000000 0.000000 1.000000 1.000000 0.000000 0.000000 1.000000 0 0
+0.500000 0.750000 1.000000 1.000000 0.000000 0.000000 1.000000 0.000000 0.000000 1.000000 1.000000 5 0
+`
+
+func TestReadGGRPalette(t *testing.T) {
+	p, err := ReadGGRPalette(strings.NewReader(testGGR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Test" {
+		t.Errorf("Expected the name Test, got %q", p.Name)
+	}
+	if len(p.Stops) != 2*GGR_SAMPLES+1 {
+		t.Errorf("Expected %d stops, got %d", 2*GGR_SAMPLES+1, len(p.Stops))
+	}
+	testCases := []struct {
+		t      float64
+		expect color.NRGBA
+	}{
+		{0, black},
+		{0.25, color.NRGBA{0x80, 0, 0, 0xff}}, // linear blend in the first segment
+		{0.5, red},
+		{0.7, red}, // step blend switches at the midpoint
+		{0.8, blue},
+		{1, blue},
+	}
+	for _, tc := range testCases {
+		if result := p.At(tc.t).NRGBA(); result != tc.expect {
+			t.Errorf("At(%v) = %v; want %v", tc.t, result, tc.expect)
+		}
+	}
+
+	if _, err := ReadGGRPalette(strings.NewReader("GIMP Gradient\n3\n")); err == nil {
+		t.Errorf("Expected an error for missing segments")
+	}
+}
+
+func TestLoadPalettes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"gray.map":   "0 0 0\n255 255 255\n",
+		"test.ggr":   testGGR,
+		"stops.csv":  "0,#000000\n1,#ff0000\n",
+		"stops.json": `{"stops":[{"pos":0,"color":"#0000ff"},{"pos":1,"color":"#ffffff"}]}`,
+		"notes.txt":  "not a palette",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	palettes, err := LoadPalettes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range palettes {
+		names = append(names, p.Name)
+	}
+	if expect := "gray stops stops Test"; strings.Join(names, " ") != expect {
+		t.Errorf("Expected palettes %q, got %q", expect, strings.Join(names, " "))
+	}
+
+	p, err := LookupPalette(filepath.Join(dir, "stops.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := p.At(0).NRGBA(); result != blue {
+		t.Errorf("Expected blue, got %v", result)
+	}
+	if p, err := LookupPalette("fire"); err != nil || p != pal_fire {
+		t.Errorf("Expected the built in fire palette, got %v, %v", p, err)
+	}
+	if _, err := LookupPalette(filepath.Join(dir, "notes.txt")); err == nil {
+		t.Errorf("Expected an error for an unknown palette type")
+	}
+	if _, err := LookupPalette("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown palette")
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// ValueSelector picks the value of a result that a Pipeline colors.
+type ValueSelector int
+
+const (
+	ValVal      ValueSelector = iota // the histogram value: density or iterations
+	ValSmooth                        // smoothed escape iterations, the value for points which didn't escape
+	ValDistance                      // closest the orbit came to 0
+	ValAngle                         // argument of the final z in turns
+	ValPeriod                        // length of the cycle the orbit fell into
+	ValMinIts                        // iteration where the orbit came closest to 0
+)
+
+var ValueSelectorName = map[int]string{
+	int(ValVal):      "val",
+	int(ValSmooth):   "smooth",
+	int(ValDistance): "distance",
+	int(ValAngle):    "angle",
+	int(ValPeriod):   "period",
+	int(ValMinIts):   "min_its",
+}
+
+func (vs ValueSelector) String() string {
+	return ValueSelectorName[int(vs)]
+}
+
+func (vs ValueSelector) MarshalText() ([]byte, error) {
+	return []byte(vs.String()), nil
+}
+
+func (vs *ValueSelector) UnmarshalText(text []byte) error {
+	for n, name := range ValueSelectorName {
+		if name == string(text) {
+			*vs = ValueSelector(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value: %q", text)
+}
+
+// Detail returns whether the value comes from CalcResult.Detail.
+func (vs ValueSelector) Detail() bool {
+	return vs != ValVal
+}
+
+// Of returns the value of a result, and whether it has one.
+func (vs ValueSelector) Of(r *CalcResult, limit float64) (float64, bool) {
+	d := r.Detail
+	switch {
+	case vs == ValVal:
+		return float64(r.Val), true
+	case vs == ValSmooth && (d == nil || !r.Escaped):
+		return float64(r.Val), true
+	case d == nil:
+		return 0, false
+	}
+
+	switch vs {
+	case ValSmooth:
+		return math.Max(d.SmoothIts(limit), 0), true
+	case ValDistance:
+		return d.MinMod, true
+	case ValAngle:
+		return argTurns(d.ZFinal), true
+	case ValPeriod:
+		return float64(d.Period), d.Period > 0
+	case ValMinIts:
+		return float64(d.MinIts), true
+	}
+	return 0, false
+}
+
+// Normalizer maps values to where they fall from 0 to 1.
+type Normalizer int
+
+const (
+	NormValue      Normalizer = iota // from 0 to the clip
+	NormPercentMax                   // from 0 to the clip percent of the max value
+	NormPercentAvg                   // from 0 to the clip percent of the average value
+	NormPercentile                   // from the floor to the clip percentile of the values
+	NormEqualize                     // by rank among the values
+	NormGolden                       // spread around by the golden ratio, for small integers and hues
+	NormNone                         // unchanged
+	NormSectors                      // which of the cfp sectors a value in turns falls in, from the first at 0 to the last at 1
+)
+
+var NormalizerName = map[int]string{
+	int(NormValue):      "value",
+	int(NormPercentMax): "percent_max",
+	int(NormPercentAvg): "percent_avg",
+	int(NormPercentile): "percentile",
+	int(NormEqualize):   "equalize",
+	int(NormGolden):     "golden",
+	int(NormNone):       "none",
+	int(NormSectors):    "sectors",
+}
+
+func (nm Normalizer) String() string {
+	return NormalizerName[int(nm)]
+}
+
+func (nm Normalizer) MarshalText() ([]byte, error) {
+	return []byte(nm.String()), nil
+}
+
+func (nm *Normalizer) UnmarshalText(text []byte) error {
+	for n, name := range NormalizerName {
+		if name == string(text) {
+			*nm = Normalizer(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown normalizer: %q", text)
+}
+
+// Clips returns whether the normalizer clips values, which are then tone
+// mapped and gamma corrected.
+func (nm Normalizer) Clips() bool {
+	return nm <= NormPercentile
+}
+
+// Ranged returns whether the normalizer places values from 0 to 1, so they
+// can be alpha as well as a position on the ramp. Sectors are categories.
+func (nm Normalizer) Ranged() bool {
+	return nm <= NormEqualize
+}
+
+// Relative returns whether the normalizer places values against the other
+// values, rather than only the cfp.
+func (nm Normalizer) Relative() bool {
+	return nm >= NormPercentMax && nm <= NormEqualize
+}
+
+// Ranks returns whether the normalizer places values by their rank among the
+// values, which the cfp points can narrow.
+func (nm Normalizer) Ranks() bool {
+	return nm == NormPercentile || nm == NormEqualize
+}
+
+// Modulator scales the normalized value of a point by another of its values.
+type Modulator int
+
+const (
+	ModNone       Modulator = iota
+	ModFieldLines           // darkened near the edges of the cfp sectors the final z's angle falls in
+)
+
+var ModulatorName = map[int]string{
+	int(ModNone):       "none",
+	int(ModFieldLines): "field_lines",
+}
+
+func (md Modulator) String() string {
+	return ModulatorName[int(md)]
+}
+
+func (md Modulator) MarshalText() ([]byte, error) {
+	return []byte(md.String()), nil
+}
+
+func (md *Modulator) UnmarshalText(text []byte) error {
+	for n, name := range ModulatorName {
+		if name == string(text) {
+			*md = Modulator(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown modulator: %q", text)
+}
+
+// Detail returns whether the modulator uses CalcResult.Detail.
+func (md Modulator) Detail() bool {
+	return md != ModNone
+}
+
+// Of returns the normalized value t of the result, modulated.
+func (md Modulator) Of(t float64, r *CalcResult, params ColorFuncParams) float64 {
+	if md != ModFieldLines || r.Detail == nil {
+		return t
+	}
+	// Distance to the nearest sector edge, 1 halfway between.
+	a := argTurns(r.Detail.ZFinal) * float64(params.sectors())
+	edge := 2 * math.Min(a-math.Floor(a), math.Ceil(a)-a)
+	if edge < FIELD_LINE_WIDTH {
+		t *= edge / FIELD_LINE_WIDTH
+	}
+	return t
+}
+
+// Ramp turns a position from 0 to 1 into a color.
+type Ramp int
+
+const (
+	RampGray    Ramp = iota // black to white
+	RampBlue                // black through blue to white
+	RampPalette             // the cfp palette
+	RampHue                 // around the hue wheel, in turns
+)
+
+var RampName = map[int]string{
+	int(RampGray):    "gray",
+	int(RampBlue):    "blue",
+	int(RampPalette): "palette",
+	int(RampHue):     "hue",
+}
+
+func (rp Ramp) String() string {
+	return RampName[int(rp)]
+}
+
+func (rp Ramp) MarshalText() ([]byte, error) {
+	return []byte(rp.String()), nil
+}
+
+func (rp *Ramp) UnmarshalText(text []byte) error {
+	for n, name := range RampName {
+		if name == string(text) {
+			*rp = Ramp(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown ramp: %q", text)
+}
+
+// HexColor is a color written as #rrggbb or #rrggbbaa.
+type HexColor color.NRGBA
+
+func (hc HexColor) MarshalText() ([]byte, error) {
+	text := fmt.Sprintf("#%02x%02x%02x", hc.R, hc.G, hc.B)
+	if hc.A != 0xff {
+		text += fmt.Sprintf("%02x", hc.A)
+	}
+	return []byte(text), nil
+}
+
+func (hc *HexColor) UnmarshalText(text []byte) error {
+	c, err := ParseHexColor(string(text))
+	*hc = HexColor(c)
+	return err
+}
+
+// Hex returns a HexColor of the linear color for a Pipeline.
+func Hex(c LinearColor) *HexColor {
+	hc := HexColor(c.NRGBA())
+	return &hc
+}
+
+func (hc *HexColor) linear() LinearColor {
+	return LinearOf(color.NRGBA(*hc))
+}
+
+// Pipeline is a ColorFunc put together from stages. Each point in the
+// Points set has its Value selected, normalized against the Against values
+// of the whole set, tone mapped and gamma corrected when it's clipped,
+// modulated, then colored by the Ramp. Percentiles and equalizing rank
+// values among the points in the cfp points too. Special colors replace the ramp for points which are
+// clipped, periodic or escaped, and color the points outside the set or
+// without a value, which are black by default. When the cfp is transparent,
+// those points are see-through instead and ramp colors take their alpha
+// from the normalized value.
+type Pipeline struct {
+	Value     ValueSelector  `json:"value"`
+	Against   *ValueSelector `json:"against,omitempty"` // the set's values to normalize against, when they aren't the Value
+	Points    PointSet       `json:"points"`
+	Normalize Normalizer     `json:"normalize"`
+	Tone      *ToneMap       `json:"tone,omitempty"` // instead of the cfp tone
+	Modulate  Modulator      `json:"modulate,omitempty"`
+	Ramp      Ramp           `json:"ramp"`
+	Exact     bool           `json:"exact,omitempty"` // color each point from its own calculation, ruling out subdivision
+
+	// Recorded uses detail only where it was recorded anyway, rather than
+	// recording it: ValSmooth falls back to the histogram value without it.
+	Recorded bool `json:"recorded,omitempty"`
+
+	Showclip bool      `json:"showclip,omitempty"` // color clipped values with the clip color when the cfp shows clipping
+	Clipped  *HexColor `json:"clipped,omitempty"`  // instead of the clip color, whether or not the cfp shows clipping
+	Periodic *HexColor `json:"periodic,omitempty"`
+	Escaped  *HexColor `json:"escaped,omitempty"`
+	Other    *HexColor `json:"other,omitempty"`
+}
+
+func (pl *Pipeline) String() string {
+	return fmt.Sprintf("Pipeline{%v of %v points, %v, %v}", pl.Value, pl.Points, pl.Normalize, pl.Ramp)
+}
+
+// ColorFunc returns the pipeline as a ColorFunc. It records detail, and so
+// is Exact, when a stage uses CalcResult.Detail, unless it's Recorded.
+func (pl Pipeline) ColorFunc(name, desc string) ColorFunc {
+	detail := !pl.Recorded && (pl.Value.Detail() || pl.against().Detail() || pl.Modulate.Detail())
+	return ColorFunc{
+		Name:       name,
+		Desc:       desc,
+		F:          pl.Colors,
+		Exact:      pl.Exact || detail,
+		Detail:     detail,
+		Palette:    pl.Ramp == RampPalette,
+		Percentile: pl.Normalize == NormPercentile,
+		Relative:   pl.Normalize.Relative(),
+		Pipeline:   &pl,
+	}
+}
+
+// normalizer returns a function normalizing the values against the clip
+// from the params, and whether a value is clipped: past where it's exposed
+// and tone mapped to white. Values are counts, so they're only clipped once
+// they reach the next count past that, except for percentiles which clip
+// past the value itself.
+func (pl *Pipeline) normalizer(vals []float64, params ColorFuncParams) (norm func(v float64) float64, clipped func(v float64) bool) {
+	var lo, hi float64
+	switch pl.Normalize {
+	case NormValue:
+		hi = params.Clip
+	case NormPercentMax:
+		for _, v := range vals {
+			hi = math.Max(hi, v)
+		}
+		hi *= params.Clip / 100
+	case NormPercentAvg:
+		for _, v := range vals {
+			hi += v
+		}
+		if len(vals) > 0 {
+			hi *= params.Clip / 100 / float64(len(vals))
+		}
+	case NormPercentile:
+		lo, hi = params.percentileRange(vals)
+	case NormEqualize:
+		eq := newEqualizer(vals)
+		return eq.At, func(float64) bool { return false }
+	case NormGolden:
+		return func(v float64) float64 { return golden * v }, func(float64) bool { return false }
+	case NormSectors:
+		n := params.sectors()
+		return func(v float64) float64 {
+			return float64(min(int(v*float64(n)), n-1)) / float64(n-1)
+		}, func(float64) bool { return false }
+	default:
+		return func(v float64) float64 { return v }, func(float64) bool { return false }
+	}
+
+	if pl.Tone != nil {
+		params.Tone = *pl.Tone
+	}
+	norm = func(v float64) float64 {
+		return params.Scale(math.Max(v-lo, 0), hi-lo, params.Gamma)
+	}
+	white := lo + params.WhiteAt(hi-lo)
+	if pl.Normalize == NormPercentile {
+		clipped = func(v float64) bool { return v > white }
+	} else {
+		clipped = func(v float64) bool { return v >= white+1 }
+	}
+	return norm, clipped
+}
+
+// Against returns a selector to normalize against, for a Pipeline.
+func Against(vs ValueSelector) *ValueSelector {
+	return &vs
+}
+
+// against returns the selector of the values to normalize against.
+func (pl *Pipeline) against() ValueSelector {
+	if pl.Against == nil {
+		return pl.Value
+	}
+	return *pl.Against
+}
+
+// values returns the value of each point in the set, which is narrowed by the
+// cfp points when the normalizer ranks values, and all the values to
+// normalize against.
+func (pl *Pipeline) values(histogram CalcResults, params ColorFuncParams) (map[plane.ImagePoint]float64, []float64) {
+	vals := make(map[plane.ImagePoint]float64, len(histogram))
+	all := make([]float64, 0, len(histogram))
+	against := pl.against()
+	for xy, r := range histogram {
+		if !pl.Points.Has(r) || (pl.Normalize.Ranks() && !params.Points.Has(r)) {
+			continue
+		}
+		v, ok := pl.Value.Of(r, params.Limit)
+		if !ok {
+			continue
+		}
+		if a, ok := against.Of(r, params.Limit); ok {
+			all = append(all, a)
+		}
+		vals[xy] = v
+	}
+	return vals, all
+}
+
+// Colors colors the histogram, as a ColorFunc's F.
+func (pl *Pipeline) Colors(histogram CalcResults, params ColorFuncParams) ColorResults {
+	special := func(hc *HexColor, otherwise LinearColor) LinearColor {
+		if hc == nil {
+			return otherwise
+		}
+		return hc.linear()
+	}
+	other := special(pl.Other, Black)
+	if params.Transparent {
+		other = special(pl.Other, LinearColor{})
+	}
+	clip_color := pl.Clipped
+	if clip_color == nil && pl.Showclip && params.Showclip {
+		clip_color = Hex(clipColor)
+	}
+
+	// Values are normalized against the values of the whole set.
+	vals, all := pl.values(histogram, params)
+	norm, clipped := pl.normalizer(all, params)
+
+	coloring := make(ColorResults, len(histogram))
+	for xy, r := range histogram {
+		v, ok := vals[xy]
+		switch {
+		case !ok:
+			coloring[xy] = other
+		case r.Periodic && pl.Periodic != nil:
+			coloring[xy] = pl.Periodic.linear()
+		case r.Escaped && pl.Escaped != nil:
+			coloring[xy] = pl.Escaped.linear()
+		case clip_color != nil && clipped(v):
+			coloring[xy] = clip_color.linear()
+		default:
+			t := pl.Modulate.Of(norm(v), r, params)
+			c := pl.ramp(t, params)
+			if params.Transparent && pl.Normalize.Ranged() {
+				c.A = clamp01(t)
+			}
+			coloring[xy] = c
+		}
+	}
+	return coloring
+}
+
+func (pl *Pipeline) ramp(t float64, params ColorFuncParams) LinearColor {
+	switch pl.Ramp {
+	case RampBlue:
+		return blueRamp(t)
+	case RampPalette:
+		return params.palette().At(t)
+	case RampHue:
+		return HueColor(t)
+	}
+	return DisplayGray(t)
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// mixed returns escaped points with vals 1 to 4, a periodic point and a
+// point which ran out of iterations.
+func mixed() CalcResults {
+	histogram := make(CalcResults)
+	for x := 0; x < 4; x++ {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, uint(x+1)).Escaped = true
+	}
+	histogram.Add(plane.ImagePoint{X: 4, Y: 0}, 0, 8).Periodic = true
+	histogram.Add(plane.ImagePoint{X: 5, Y: 0}, 0, 8)
+	return histogram
+}
+
+func TestPipelinePresets(t *testing.T) {
+	histogram := mixed()
+	params := ColorFuncParams{Clip: 50, Gamma: 1}
+	testCases := []struct {
+		cf     ColorFunc
+		expect func(r *CalcResult) LinearColor
+	}{
+		{cf_luma_clip_percent_max, func(r *CalcResult) LinearColor {
+			return DisplayGray(params.Scale(float64(r.Val), 4, 1))
+		}},
+		{cf_escaped_clip_value, func(r *CalcResult) LinearColor {
+			if !r.Escaped {
+				return Black
+			}
+			return blueRamp(params.Scale(float64(r.Val), 50, 1))
+		}},
+		{cf_escaped_1bit, func(r *CalcResult) LinearColor {
+			if !r.Escaped {
+				return Black
+			}
+			return White
+		}},
+	}
+	for _, tc := range testCases {
+		coloring := tc.cf.F(histogram, params)
+		for xy, r := range histogram {
+			if expect := tc.expect(r); !closeColor(coloring[xy], expect) {
+				t.Errorf("%v: %v is %v; want %v", tc.cf, xy, coloring[xy], expect)
+			}
+		}
+	}
+
+	if !cf_luma_clip_value.Exact || cf_palette_clip_value.Exact || !cf_palette_clip_value.Palette {
+		t.Errorf("Presets lost their flags")
+	}
+	if !cf_interior_period.Detail || !cf_interior_period.Exact {
+		t.Errorf("Expected %v to record detail", cf_interior_period)
+	}
+	if cf_palette_equalized.Detail || cf_palette_equalized.Exact {
+		t.Errorf("Expected %v not to need detail", cf_palette_equalized)
+	}
+	if cf_palette_clip_value.Relative || cf_interior_period.Relative || !cf_palette_clip_percent_avg.Relative || !cf_palette_equalized.Relative {
+		t.Errorf("Expected only the presets normalizing against the histogram to be relative")
+	}
+}
+
+func TestPipelineSpecialColors(t *testing.T) {
+	red, green, blue := Hex(LinearColor{1, 0, 0, 1}), Hex(LinearColor{0, 1, 0, 1}), Hex(LinearColor{0, 0, 1, 1})
+	pl := Pipeline{
+		Normalize: NormValue,
+		Clipped:   red,
+		Periodic:  green,
+		Other:     blue,
+		Points:    EscapedPoints,
+	}
+	histogram := mixed()
+	histogram.Add(plane.ImagePoint{X: 2, Y: 0}, 0, 10) // clipped
+	coloring := pl.Colors(histogram, ColorFuncParams{Clip: 4, Gamma: 1})
+
+	expect := map[int]LinearColor{
+		0: DisplayGray(0.25),
+		2: red.linear(),  // clipped
+		3: White,         // at the clip
+		4: blue.linear(), // outside the set, even though it's periodic
+		5: blue.linear(), // outside the set
+	}
+	for x, c := range expect {
+		if result := coloring[plane.ImagePoint{X: x, Y: 0}]; !closeColor(result, c) {
+			t.Errorf("%d is %v; want %v", x, result, c)
+		}
+	}
+}
+
+func TestPipelineEmptySet(t *testing.T) {
+	histogram := make(CalcResults)
+	histogram.Add(plane.ImagePoint{X: 0, Y: 0}, 0, 8)
+	pl := Pipeline{Points: EscapedPoints, Normalize: NormPercentAvg, Other: Hex(White)}
+	if c := pl.Colors(histogram, ColorFuncParams{Clip: 50, Gamma: 1})[plane.ImagePoint{X: 0, Y: 0}]; !closeColor(c, White) {
+		t.Errorf("Point outside an empty set is %v; want white", c)
+	}
+	norm, _ := pl.normalizer(nil, ColorFuncParams{Clip: 50, Gamma: 1})
+	if v := norm(1); math.IsNaN(v) {
+		t.Errorf("Expected no NaN normalizing against an empty set, got %v", v)
+	}
+}
+
+func TestPipelineSceneRoundTrip(t *testing.T) {
+	text := `{"value": "smooth", "against": "val", "points": "all", "normalize": "percentile", "tone": "log",
+		"modulate": "field_lines", "ramp": "palette", "periodic": "#ff0000"}`
+	var pl Pipeline
+	if err := json.Unmarshal([]byte(text), &pl); err != nil {
+		t.Fatal(err)
+	}
+	if pl.Tone == nil || *pl.Tone != LogTone || pl.Against == nil || *pl.Against != ValVal ||
+		pl.Modulate != ModFieldLines || pl.Periodic == nil || pl.Periodic.R != 0xff {
+		t.Fatalf("Unmarshaled %+v", pl)
+	}
+
+	cp := NewCalcParams(*coldwave2_fire)
+	cp.CF = pl.ColorFunc("fire_percentile", "")
+	data, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"pipeline"`) {
+		t.Errorf("Expected a custom pipeline in %s", data)
+	}
+	result := new(CalcParams)
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatal(err)
+	}
+	expect, _ := json.Marshal(pl)
+	got, _ := json.Marshal(result.CF.Pipeline)
+	if result.CF.Name != "fire_percentile" || !result.CF.Percentile || string(got) != string(expect) {
+		t.Errorf("Expected the custom pipeline %s, got %s", expect, got)
+	}
+
+	// Presets are stored by name.
+	if data, _ := json.Marshal(coldwave2_fire); strings.Contains(string(data), `"pipeline"`) {
+		t.Errorf("Expected only the preset's name in %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"ramp": "plaid"}`), &pl); err == nil {
+		t.Error("Expected an error for an unknown ramp")
+	}
+}
+
+// legacyColorFuncs are the ColorFuncs as they were written before they were
+// rebuilt as Pipeline presets, for checking the presets color the same.
+var legacyColorFuncs = func() map[string]func(CalcResults, ColorFuncParams) ColorResults {
+	clipValue := func(histogram CalcResults, params ColorFuncParams) float64 {
+		return params.Clip
+	}
+	clipAvg := func(histogram CalcResults, params ColorFuncParams) float64 {
+		return (params.Clip / 100) * histogram.Avg()
+	}
+	clipMax := func(histogram CalcResults, params ColorFuncParams) float64 {
+		return (params.Clip / 100) * histogram.Max()
+	}
+	clipAvgEscaped := func(histogram CalcResults, params ColorFuncParams) float64 {
+		return (params.Clip / 100) * histogram.AvgEscaped()
+	}
+	clipMaxEscaped := func(histogram CalcResults, params ColorFuncParams) float64 {
+		return (params.Clip / 100) * histogram.MaxEscaped()
+	}
+
+	// Every point clips at max, shown when the params show clipping.
+	clipping := func(clip func(CalcResults, ColorFuncParams) float64, ramp func(ColorFuncParams) func(float64) LinearColor) func(CalcResults, ColorFuncParams) ColorResults {
+		return func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			max := clip(histogram, params)
+			for xy, v := range histogram {
+				val := float64(v.Val)
+				if params.Showclip && val >= max+1 {
+					coloring[xy] = clipColor
+				} else {
+					coloring[xy] = ramp(params)(params.Scale(val, max, params.Gamma))
+				}
+			}
+			return coloring
+		}
+	}
+	// Escaped points are blue, the rest black, and clipping isn't shown.
+	escaped := func(clip func(CalcResults, ColorFuncParams) float64) func(CalcResults, ColorFuncParams) ColorResults {
+		return func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			max := clip(histogram, params)
+			for xy, v := range histogram {
+				if v.Escaped {
+					coloring[xy] = blueRamp(params.Scale(float64(v.Val), max, params.Gamma))
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		}
+	}
+	percentile := func(ramp func(ColorFuncParams) func(float64) LinearColor) func(CalcResults, ColorFuncParams) ColorResults {
+		return func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			ps := histogram.Percentiles(params.Points, params.Floor, params.Clip)
+			lo, hi := ps[0], ps[1]
+			if !(hi > lo) {
+				hi = lo + 1
+			}
+			for xy, v := range histogram {
+				val := float64(v.Val)
+				switch {
+				case !params.Points.Has(v):
+					coloring[xy] = Black
+				case params.Showclip && val > hi:
+					coloring[xy] = clipColor
+				default:
+					coloring[xy] = ramp(params)(params.Scale(math.Max(val-lo, 0), hi-lo, params.Gamma))
+				}
+			}
+			return coloring
+		}
+	}
+	gray := func(ColorFuncParams) func(float64) LinearColor { return DisplayGray }
+	palette := func(params ColorFuncParams) func(float64) LinearColor { return params.palette().At }
+
+	return map[string]func(CalcResults, ColorFuncParams) ColorResults{
+		"luma_clip_value":          clipping(clipValue, gray),
+		"luma_clip_percent_avg":    clipping(clipAvg, gray),
+		"luma_clip_percent_max":    clipping(clipMax, gray),
+		"palette_clip_value":       clipping(clipValue, palette),
+		"palette_clip_percent_avg": clipping(clipAvg, palette),
+		"palette_clip_percent_max": clipping(clipMax, palette),
+		"escaped_clip_value":       escaped(clipValue),
+		"escaped_clip_percent_avg": escaped(clipAvgEscaped),
+		"escaped_clip_percent_max": escaped(clipMaxEscaped),
+		"luma_clip_percentile":     percentile(gray),
+		"palette_clip_percentile":  percentile(palette),
+		"escaped_1bit": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			for xy, v := range histogram {
+				if v.Escaped {
+					coloring[xy] = White
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"escaped_smooth_clip_percent_max": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			max := clipMaxEscaped(histogram, params)
+			for xy, v := range histogram {
+				if v.Escaped && v.Detail != nil {
+					luma := params.Scale(math.Max(v.Detail.SmoothIts(params.Limit), 0), max, params.Gamma)
+					coloring[xy] = blueRamp(luma)
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"escaped_decomposition": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			palette := params.palette()
+			n := params.sectors()
+			for xy, v := range histogram {
+				if v.Escaped && v.Detail != nil {
+					sector := min(int(argTurns(v.Detail.ZFinal)*float64(n)), n-1)
+					coloring[xy] = palette.At(float64(sector) / float64(n-1))
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"escaped_field_lines": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			max := clipMaxEscaped(histogram, params)
+			n := float64(params.sectors())
+			for xy, v := range histogram {
+				if v.Escaped && v.Detail != nil {
+					luma := params.Scale(math.Max(v.Detail.SmoothIts(params.Limit), 0), max, params.Gamma)
+					t := argTurns(v.Detail.ZFinal) * n
+					edge := 2 * math.Min(t-math.Floor(t), math.Ceil(t)-t)
+					if edge < FIELD_LINE_WIDTH {
+						luma *= edge / FIELD_LINE_WIDTH
+					}
+					coloring[xy] = blueRamp(luma)
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"interior_period": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			for xy, v := range histogram {
+				if !v.Escaped && v.Detail != nil && v.Detail.Period > 0 {
+					coloring[xy] = HueColor(golden * float64(v.Detail.Period))
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"atom_domains": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			for xy, v := range histogram {
+				if v.Detail != nil {
+					coloring[xy] = HueColor(golden * float64(v.Detail.MinIts))
+				} else {
+					coloring[xy] = Black
+				}
+			}
+			return coloring
+		},
+		"palette_equalized": func(histogram CalcResults, params ColorFuncParams) ColorResults {
+			coloring := make(ColorResults)
+			eq := NewEqualizer(histogram, params.Points)
+			palette := params.palette()
+			for xy, v := range histogram {
+				if !params.Points.Has(v) {
+					coloring[xy] = Black
+					continue
+				}
+				val := float64(v.Val)
+				if v.Escaped && v.Detail != nil {
+					val = math.Max(v.Detail.SmoothIts(params.Limit), 0)
+				}
+				coloring[xy] = palette.At(eq.At(val))
+			}
+			return coloring
+		},
+	}
+}()
+
+// detailed returns escaped points with iterations 3 to 30, periodic and
+// aperiodic points which ran out of iterations, and their detail. The final
+// zs are all around the circle and at different distances past the limit,
+// so smoothed iterations fall between the counts.
+func detailed() CalcResults {
+	histogram := make(CalcResults)
+	for x := 0; x < 10; x++ {
+		its := 3 * (x + 1)
+		r := histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, uint(its))
+		r.Escaped = true
+		r.Detail = &CalcDetail{Its: its, ZFinal: cmplx.Rect(2.1+0.7*float64(x), 0.9*float64(x)-3), MinIts: x % 4}
+	}
+	for x := 0; x < 6; x++ {
+		r := histogram.Add(plane.ImagePoint{X: x, Y: 1}, 0, 40)
+		r.Detail = &CalcDetail{Its: 40, ZFinal: 0.1, Period: x % 3, MinIts: x}
+		r.Periodic = r.Detail.Period > 0
+	}
+	return histogram
+}
+
+// TestPipelinePresetsMatchLegacy checks each preset colors as the ColorFunc it
+// replaced did, with and without detail unless the preset records it.
+func TestPipelinePresetsMatchLegacy(t *testing.T) {
+	withDetail := detailed()
+	withoutDetail := make(CalcResults)
+	for xy, r := range withDetail {
+		v := *r
+		v.Detail = nil
+		withoutDetail[xy] = &v
+	}
+
+	paramSets := []ColorFuncParams{
+		{Clip: 20, Gamma: 1, Limit: 2},
+		{Clip: 20, Gamma: 1, Limit: 2, Showclip: true},
+		{Clip: 75, Gamma: 2.2, Limit: 2, Showclip: true, Points: EscapedPoints, Palette: pal_fire},
+		{Clip: 50, Gamma: 1.5, Limit: 2, Showclip: true, Points: InteriorPoints, Floor: 10, Tone: LogTone},
+		{Clip: 90, Gamma: 1, Limit: 2, Floor: 5, Palette: pal_rainbow, Sectors: 5},
+		{Clip: 100, Gamma: 2.2, Limit: 3, Sectors: 8, Palette: pal_fire},
+	}
+	for name, legacy := range legacyColorFuncs {
+		cf, ok := ColorFuncs[name]
+		if !ok || cf.Pipeline == nil {
+			t.Errorf("Expected %s to be a pipeline preset", name)
+			continue
+		}
+		histograms := []CalcResults{withDetail}
+		if !cf.Detail {
+			histograms = append(histograms, withoutDetail)
+		}
+		for _, histogram := range histograms {
+			for _, params := range paramSets {
+				expect := legacy(histogram, params)
+				coloring := cf.F(histogram, params)
+				for xy := range histogram {
+					if !closeColor(coloring[xy], expect[xy]) {
+						t.Errorf("%s %+v: %v is %v; want %v", name, params, xy, coloring[xy], expect[xy])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPipelineClipsAtWhite(t *testing.T) {
+	// 150 rolls off short of white at 2, 200 is white, and 201 is past it.
+	histogram := make(CalcResults)
+	for x, val := range []uint{150, 200, 201, 60} {
+		histogram.Add(plane.ImagePoint{X: x, Y: 0}, 0, val)
+	}
+	red := Hex(LinearColor{1, 0, 0, 1})
+	testCases := []struct {
+		cfp     ColorFuncParams
+		clipped []bool
+	}{
+		{ColorFuncParams{Clip: 100}, []bool{true, true, true, false}},
+		{ColorFuncParams{Clip: 100, Tone: ReinhardTone, White: 2}, []bool{false, false, true, false}},
+		{ColorFuncParams{Clip: 100, Tone: ACESTone, White: 2}, []bool{false, false, true, false}},
+		{ColorFuncParams{Clip: 100, Tone: ReinhardTone, White: 2, Exposure: 2}, []bool{true, true, true, true}},
+		{ColorFuncParams{Clip: 100, Exposure: -1}, []bool{false, false, true, false}},
+	}
+	for _, tc := range testCases {
+		pl := Pipeline{Normalize: NormValue, Clipped: red}
+		coloring := pl.Colors(histogram, tc.cfp)
+		for x, expect := range tc.clipped {
+			c := coloring[plane.ImagePoint{X: x, Y: 0}]
+			if result := c == red.linear(); result != expect {
+				t.Errorf("%v exposure %v white %v: %d clipped = %v; want %v", tc.cfp.Tone, tc.cfp.Exposure, tc.cfp.White, x, result, expect)
+			}
+		}
+	}
+}
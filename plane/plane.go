@@ -1,12 +1,16 @@
 package plane
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
+	"io"
 	"math"
 	"os"
 )
@@ -16,11 +20,12 @@ type Plane struct {
 	origin, size complex128
 	view         PlaneView
 	inverted     bool
+	transparent  bool // the image background is transparent instead of black
 
 	r_step, i_step float64
 	x_step, y_step float64
 
-	image *image.NRGBA
+	image draw.Image // *image.NRGBA, or *image.NRGBA64 for 16 bit depth
 }
 
 // NewPlane returns a new Plane.
@@ -60,24 +65,70 @@ func NewPlane(origin, size complex128, y_pixels int) *Plane {
 	return &p
 }
 
+// WithDepth returns the same Plane with an image of 8 or 16 bits per channel.
+// Any depth but 16 is 8, so callers should check it first. Changing the depth
+// resets the image.
+func (p *Plane) WithDepth(bits int) *Plane {
+	if bits != 16 {
+		bits = 8
+	}
+	if bits == p.Depth() {
+		return p
+	}
+	r := p.image.Bounds()
+	if bits == 16 {
+		p.image = image.NewNRGBA64(r)
+	} else {
+		p.image = image.NewNRGBA(r)
+	}
+	p.ResetImage()
+	return p
+}
+
+// Depth returns the bits per channel of the image.
+func (p *Plane) Depth() int {
+	if _, ok := p.image.(*image.NRGBA64); ok {
+		return 16
+	}
+	return 8
+}
+
 // WithInverted returns the same Plane with Inverted true.
 func (p *Plane) WithInverted() *Plane {
 	p.inverted = true
 	return p
 }
 
+// WithTransparent returns the same Plane with a transparent background.
+// The image is reset.
+func (p *Plane) WithTransparent() *Plane {
+	p.transparent = true
+	p.ResetImage()
+	return p
+}
+
+// IsTransparent returns whether the image background is transparent.
+func (p *Plane) IsTransparent() bool {
+	return p.transparent
+}
+
+// like gives a new Plane the same inversion, depth and background as p.
+func (p *Plane) like(new *Plane) *Plane {
+	new.inverted = p.inverted
+	if p.transparent {
+		new.WithTransparent()
+	}
+	return new.WithDepth(p.Depth())
+}
+
 // NewOrigin returns a new Plane with the given origin.
 func (p *Plane) NewOrigin(origin complex128) *Plane {
-	new := NewPlane(origin, p.size, p.ImageHeight())
-	new.inverted = p.inverted
-	return new
+	return p.like(NewPlane(origin, p.size, p.ImageHeight()))
 }
 
 // NewSize returns a new Plane with the given complex plane size.
 func (p *Plane) NewSize(size complex128) *Plane {
-	new := NewPlane(p.origin, size, p.ImageHeight())
-	new.inverted = p.inverted
-	return new
+	return p.like(NewPlane(p.origin, size, p.ImageHeight()))
 }
 
 // NewImageSize returns a new Plane with the given image size.
@@ -89,9 +140,7 @@ func (p *Plane) NewImageSize(width, height int) *Plane {
 		size = complex(real(p.size), real(p.size)*(1.0/aspect)) // keep r size
 	}
 
-	new := NewPlane(p.origin, size, height)
-	new.inverted = p.inverted
-	return new
+	return p.like(NewPlane(p.origin, size, height))
 }
 
 func (p *Plane) String() string {
@@ -100,13 +149,21 @@ func (p *Plane) String() string {
 		p.origin, p.view, p.ImageWidth(), p.ImageHeight())
 }
 
-// ResetImage resets the image to all black.
+// ResetImage resets the image to all black, or all transparent.
 func (p *Plane) ResetImage() {
-	draw.Draw(p.image, p.image.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(p.image, p.image.Bounds(), image.NewUniform(p.Background()), image.Point{}, draw.Src)
+}
+
+// Background returns the color of an empty image: black, or transparent.
+func (p *Plane) Background() color.Color {
+	if p.transparent {
+		return color.Transparent
+	}
+	return color.Black
 }
 
 // Image returns the image buffer.
-func (p *Plane) Image() *image.NRGBA {
+func (p *Plane) Image() draw.Image {
 	return p.image
 }
 
@@ -192,37 +249,93 @@ func (p *Plane) ToImagePoint(z complex128) ImagePoint {
 }
 
 // SetZColor sets the color in the image plane corresponding to the given plane point.
-func (p *Plane) SetZColor(z complex128, rgba color.NRGBA) {
+func (p *Plane) SetZColor(z complex128, rgba color.Color) {
 	xy := p.ToImagePoint(z)
 	p.image.Set(xy.X, xy.Y, rgba)
 }
 
 // SetXYColor sets the color in the image plane corresponding to the given image point.
-func (p *Plane) SetXYColor(x, y int, rgba color.NRGBA) {
+func (p *Plane) SetXYColor(x, y int, rgba color.Color) {
 	p.image.Set(x, y, rgba)
 }
 
 // ImageWidth returns the image width.
 func (p *Plane) ImageWidth() int {
-	return p.image.Rect.Dx()
+	return p.image.Bounds().Dx()
 }
 
 // ImageHeight returns the image width.
 func (p *Plane) ImageHeight() int {
-	return p.image.Rect.Dy()
+	return p.image.Bounds().Dy()
 }
 
 // WritePNG outputs a PNG file at the given path.
 func (p *Plane) WritePNG(path string) {
 	png_file, _ := os.Create(path)
-	penc := png.Encoder{CompressionLevel: png.BestCompression}
-	if err := penc.Encode(png_file, p.image); err != nil {
+	if err := EncodePNG(png_file, p.image); err != nil {
 		fmt.Printf("Error encoding PNG: %v\n", err)
 	}
 	fmt.Printf("Wrote %s\n", png_file.Name())
 	png_file.Close()
 }
 
+// EncodePNG writes img as a PNG tagged with sRGB and gAMA chunks, so viewers
+// know the pixels are sRGB encoded rather than guessing.
+func EncodePNG(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	penc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := penc.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	// The chunks must come before the image data, so they go right after
+	// the 8 byte signature and the IHDR chunk (length, type, 13 bytes of
+	// data and CRC).
+	const ihdr_end = 8 + 4 + 4 + 13 + 4
+	data := buf.Bytes()
+	if _, err := w.Write(data[:ihdr_end]); err != nil {
+		return err
+	}
+	if err := writePNGChunk(w, "sRGB", []byte{0}); err != nil { // perceptual intent
+		return err
+	}
+	gamma := binary.BigEndian.AppendUint32(nil, 45455) // 1/2.2 times 100000
+	if err := writePNGChunk(w, "gAMA", gamma); err != nil {
+		return err
+	}
+	_, err := w.Write(data[ihdr_end:])
+	return err
+}
+
+// writePNGChunk writes a PNG chunk of the given type.
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
+
+// ReadPNG replaces the image with the PNG file at the given path, which must be the same size.
+func (p *Plane) ReadPNG(path string) error {
+	png_file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer png_file.Close()
+
+	img, err := png.Decode(png_file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if img.Bounds().Size() != p.image.Bounds().Size() {
+		return fmt.Errorf("%s: image is %v, expected %v", path, img.Bounds().Size(), p.image.Bounds().Size())
+	}
+	draw.Draw(p.image, p.image.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nil
+}
+
 // planeJSON is the JSON representation of a Plane.
 type planeJSON struct {
 	Origin    [2]float64 `json:"origin"`
@@ -1,8 +1,12 @@
 package plane
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"testing"
 )
 
@@ -258,3 +262,78 @@ func TestPlaneJSONUnmarshaler(t *testing.T) {
 		t.Error(result.inverted, expect.inverted)
 	}
 }
+
+func TestEncodePNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.NRGBA{0x12, 0x34, 0x56, 0xff})
+
+	var buf bytes.Buffer
+	if err := EncodePNG(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	srgb, gama, idat := bytes.Index(data, []byte("sRGB")), bytes.Index(data, []byte("gAMA")), bytes.Index(data, []byte("IDAT"))
+	if srgb < 0 || gama < 0 || srgb > idat || gama > idat {
+		t.Errorf("Expected sRGB (%d) and gAMA (%d) chunks before IDAT (%d)", srgb, gama, idat)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decoding: %v", err)
+	}
+	if c := color.NRGBAModel.Convert(decoded.At(1, 1)); c != img.At(1, 1) {
+		t.Errorf("Expected %v, got %v", img.At(1, 1), c)
+	}
+}
+
+func TestWithDepth(t *testing.T) {
+	p := NewPlane(complex(0, 0), complex(2, 2), 10).WithDepth(16)
+	if p.Depth() != 16 {
+		t.Fatalf("Expected depth 16, got %d", p.Depth())
+	}
+	if _, ok := p.Image().(*image.NRGBA64); !ok {
+		t.Errorf("Expected an NRGBA64 image, got %T", p.Image())
+	}
+	if resized := p.NewImageSize(20, 20); resized.Depth() != 16 {
+		t.Errorf("Expected NewImageSize to keep depth 16, got %d", resized.Depth())
+	}
+
+	c := color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xffff}
+	p.SetXYColor(1, 2, c)
+	var buf bytes.Buffer
+	if err := EncodePNG(&buf, p.Image()); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := color.NRGBA64Model.Convert(decoded.At(1, 2)); result != c {
+		t.Errorf("Expected a 16 bit PNG with %v, got %v", c, result)
+	}
+
+	if p.WithDepth(8).Depth() != 8 {
+		t.Errorf("Expected depth 8")
+	}
+}
+
+func TestWithTransparent(t *testing.T) {
+	p := NewPlane(complex(0, 0), complex(2, 2), 10)
+	if _, _, _, a := p.Image().At(3, 3).RGBA(); a != 0xffff {
+		t.Fatalf("Expected an opaque black background, got alpha %#x", a)
+	}
+	p.WithTransparent()
+	if _, _, _, a := p.Image().At(3, 3).RGBA(); a != 0 {
+		t.Errorf("Expected a transparent background, got alpha %#x", a)
+	}
+
+	for _, moved := range []*Plane{p.NewOrigin(1), p.NewSize(4 + 4i), p.NewImageSize(20, 10), p.WithDepth(16)} {
+		if !moved.IsTransparent() {
+			t.Errorf("Expected %v to stay transparent", moved)
+		}
+		if _, _, _, a := moved.Image().At(3, 3).RGBA(); a != 0 {
+			t.Errorf("Expected %v to have a transparent background, got alpha %#x", moved, a)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scene files are CalcParams as JSON, the same as in the header of histogram
+// archives, so a render can be described without adding a preset. Channel
+// funcs are code, so a scene gives the name of one of the ChannelFuncs.
+
+// ReadScene reads the scene file at path.
+func ReadScene(path string) (*CalcParams, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	params := new(CalcParams)
+	if err := json.Unmarshal(data, params); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return params, nil
+}
+
+// WriteScene writes the params to path as a scene file.
+func (cp *CalcParams) WriteScene(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644) //nolint:gosec
+}
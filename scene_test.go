@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSceneRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scene.json")
+	if err := coldwave2_fire.WriteScene(path); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ReadScene(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != coldwave2_fire.String() {
+		t.Errorf("Expected\n%v\ngot\n%v", coldwave2_fire, result)
+	}
+	if result.CFP.Palette == nil || result.CFP.Palette.At(0.5) != pal_fire.At(0.5) {
+		t.Errorf("Expected the fire palette, got %v", result.CFP.Palette)
+	}
+}
+
+func TestScenePromotesPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thumb.json")
+	if err := coldwave2_fire.Preview(4).WriteScene(path); err != nil {
+		t.Fatal(err)
+	}
+	preview, err := ReadScene(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := preview.Promote()
+	if full == preview || full.Complex64 || full.String() != coldwave2_fire.String() {
+		t.Errorf("Expected the preview to promote to\n%v\ngot\n%v", coldwave2_fire, full)
+	}
+}
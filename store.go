@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// SampleStore counts orbit points on a fine grid over an area of the complex
+// plane. Since the points are binned by complex coordinates rather than
+// pixels, any view inside the store's can be rasterized without recalculating
+// the orbits. Bins are updated atomically so one store can be shared by all
+// the calculating routines, and saturate at math.MaxUint32 rather than wrap.
+type SampleStore struct {
+	View          plane.PlaneView
+	Width, Height int
+	Counts        []uint32
+}
+
+// NewSampleStore returns an empty store with width x height bins over view.
+func NewSampleStore(view plane.PlaneView, width, height int) *SampleStore {
+	return &SampleStore{View: view, Width: width, Height: height, Counts: make([]uint32, width*height)}
+}
+
+// NewPlaneSampleStore returns an empty store over the plane's view with
+// scale x scale bins for every pixel.
+func NewPlaneSampleStore(p *plane.Plane, scale int) *SampleStore {
+	return NewSampleStore(p.View(), scale*p.ImageWidth(), scale*p.ImageHeight())
+}
+
+func (s *SampleStore) String() string {
+	return fmt.Sprintf("SampleStore{View:%v, Bins:%dx%d}", s.View, s.Width, s.Height)
+}
+
+// bin returns the index of the bin for z, or -1 if z is outside the view.
+func (s *SampleStore) bin(z complex128) int {
+	x := int(math.Floor((real(z) - real(s.View.Min)) / s.View.RealLen() * float64(s.Width)))
+	y := int(math.Floor((imag(z) - imag(s.View.Min)) / s.View.ImagLen() * float64(s.Height)))
+	if x < 0 || x >= s.Width || y < 0 || y >= s.Height {
+		return -1
+	}
+	return y*s.Width + x
+}
+
+// center returns the point at the center of bin n.
+func (s *SampleStore) center(n int) complex128 {
+	x, y := n%s.Width, n/s.Width
+	return s.View.Min + complex(
+		(float64(x)+0.5)*s.View.RealLen()/float64(s.Width),
+		(float64(y)+0.5)*s.View.ImagLen()/float64(s.Height))
+}
+
+// Add counts z if it's inside the store's view.
+func (s *SampleStore) Add(z complex128) {
+	if n := s.bin(z); n >= 0 {
+		s.add(n, 1)
+	}
+}
+
+// add adds count to bin n, saturating.
+func (s *SampleStore) add(n int, count uint32) {
+	for {
+		old := atomic.LoadUint32(&s.Counts[n])
+		sum := old + count
+		if sum < old {
+			sum = math.MaxUint32
+		}
+		if sum == old || atomic.CompareAndSwapUint32(&s.Counts[n], old, sum) {
+			return
+		}
+	}
+}
+
+// Merge adds the counts from src, which must have the same view and bins.
+func (s *SampleStore) Merge(src *SampleStore) error {
+	if s.View != src.View || s.Width != src.Width || s.Height != src.Height {
+		return fmt.Errorf("sample stores differ: %v != %v", s, src)
+	}
+	for n, count := range src.Counts {
+		s.add(n, count)
+	}
+	return nil
+}
+
+// Rasterize returns a histogram for the plane by adding up the bins whose
+// centers fall in each pixel. Views outside the store are empty, and pixels
+// smaller than the bins will leave gaps.
+func (s *SampleStore) Rasterize(p *plane.Plane) (histogram CalcResults) {
+	histogram = make(CalcResults)
+	width, height := p.ImageWidth(), p.ImageHeight()
+	for n, count := range s.Counts {
+		if count == 0 {
+			continue
+		}
+		z := s.center(n)
+		xy := p.ToImagePoint(z)
+		if xy.X < 0 || xy.X >= width || xy.Y < 0 || xy.Y >= height {
+			continue
+		}
+		histogram.Add(xy, z, uint(count))
+	}
+	return
+}
+
+// Sample stores are gzip compressed and contain the magic "BAES", a uint16
+// version, a uint32 length and JSON sampleStoreHeader, and then the counts
+// as little endian uint32 in rows from the view's min point.
+const (
+	STORE_MAGIC   = "BAES"
+	STORE_VERSION = 1
+
+	MAX_STORE_BINS = 1 << 28 // bins read from a sample store, 1GB of counts
+)
+
+// sampleStoreHeader is the JSON header of a sample store file.
+type sampleStoreHeader struct {
+	View   [4]float64  `json:"view"`
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Params *CalcParams `json:"params,omitempty"`
+}
+
+// WriteStore writes the store, along with the params that filled it, to path.
+func (s *SampleStore) WriteStore(path string, params *CalcParams) error {
+	header, err := json.Marshal(sampleStoreHeader{
+		View:   [4]float64{real(s.View.Min), imag(s.View.Min), real(s.View.Max), imag(s.View.Max)},
+		Width:  s.Width,
+		Height: s.Height,
+		Params: params,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	bw := bufio.NewWriter(zw)
+	buf := []byte(STORE_MAGIC)
+	buf = binary.LittleEndian.AppendUint16(buf, STORE_VERSION)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	for _, count := range s.Counts {
+		if _, err := bw.Write(binary.LittleEndian.AppendUint32(buf[:0], count)); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%dx%d bins)\n", path, s.Width, s.Height)
+	return f.Close()
+}
+
+// ReadStore reads the sample store at path, returning it and the params that filled it.
+func ReadStore(path string) (*SampleStore, *CalcParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	br := bufio.NewReader(zr)
+
+	prefix := make([]byte, len(STORE_MAGIC)+2+4)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !bytes.Equal(prefix[:len(STORE_MAGIC)], []byte(STORE_MAGIC)) {
+		return nil, nil, fmt.Errorf("%s: not a sample store", path)
+	}
+	if version := binary.LittleEndian.Uint16(prefix[len(STORE_MAGIC):]); version != STORE_VERSION {
+		return nil, nil, fmt.Errorf("%s: unsupported sample store version %d", path, version)
+	}
+
+	header_len := binary.LittleEndian.Uint32(prefix[len(STORE_MAGIC)+2:])
+	if header_len > MAX_HEADER_LEN {
+		return nil, nil, fmt.Errorf("%s: header is %d bytes, more than %d", path, header_len, MAX_HEADER_LEN)
+	}
+	header_data := make([]byte, header_len)
+	if _, err := io.ReadFull(br, header_data); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var header sampleStoreHeader
+	if err := json.Unmarshal(header_data, &header); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if header.Width <= 0 || header.Height <= 0 || header.Width > MAX_STORE_BINS/header.Height {
+		return nil, nil, fmt.Errorf("%s: can't read %dx%d bins, more than %d or none", path, header.Width, header.Height, MAX_STORE_BINS)
+	}
+
+	view := plane.PlaneView{
+		Min: complex(header.View[0], header.View[1]), Max: complex(header.View[2], header.View[3])}
+	s := NewSampleStore(view, header.Width, header.Height)
+	buf := make([]byte, 4)
+	for n := range s.Counts {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, nil, fmt.Errorf("%s: reading counts: %w", path, err)
+		}
+		s.Counts[n] = binary.LittleEndian.Uint32(buf)
+	}
+	return s, header.Params, nil
+}
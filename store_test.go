@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestSampleStoreRasterize(t *testing.T) {
+	view := plane.PlaneView{Min: complex(-1, -1), Max: complex(1, 1)}
+	store := NewSampleStore(view, 8, 8)
+	store.Add(complex(-0.9, 0.9)) // top left
+	store.Add(complex(-0.6, 0.6)) // top left
+	store.Add(complex(0.3, -0.3)) // bottom right
+	store.Add(complex(5, 5))      // outside
+
+	// A plane the same size as the store with 2x2 pixels.
+	p := plane.NewPlane(complex(0, 0), complex(2, 2), 2)
+	result := store.Rasterize(p)
+
+	if len(result) != 2 {
+		t.Fatalf("len(result) = %d; want 2: %v", len(result), result)
+	}
+	top_left := result[plane.ImagePoint{X: 0, Y: 0}]
+	if top_left == nil || top_left.Val != 2 {
+		t.Errorf("Expected 2 samples in the top left, got %v", top_left)
+	}
+	bottom_right := result[plane.ImagePoint{X: 1, Y: 1}]
+	if bottom_right == nil || bottom_right.Val != 1 {
+		t.Errorf("Expected 1 sample in the bottom right, got %v", bottom_right)
+	}
+
+	// A zoomed in view only sees its part of the store.
+	zoomed := plane.NewPlane(complex(0.5, -0.5), complex(1, 1), 4)
+	if result := store.Rasterize(zoomed); result.Sum() != 1 {
+		t.Errorf("Expected 1 sample in the zoomed view, got %d", result.Sum())
+	}
+}
+
+func TestSampleStoreRoundTrip(t *testing.T) {
+	view := plane.PlaneView{Min: complex(-1, -1), Max: complex(1, 1)}
+	expect := NewSampleStore(view, 4, 2)
+	expect.Add(complex(0.1, 0.1))
+	expect.Add(complex(0.1, 0.1))
+	expect.Add(complex(-0.9, -0.9))
+	params := NewCalcParams(CalcParams{
+		Plane: plane.NewPlane(complex(0, 0), complex(2, 2), 8), Style: Attractor, ZF: zf_klein})
+
+	path := filepath.Join(t.TempDir(), "test.baes")
+	if err := expect.WriteStore(path, params); err != nil {
+		t.Fatalf("WriteStore Error: %v", err)
+	}
+	result, result_params, err := ReadStore(path)
+	if err != nil {
+		t.Fatalf("ReadStore Error: %v", err)
+	}
+
+	if result.View != expect.View || result.Width != expect.Width || result.Height != expect.Height {
+		t.Errorf("Expected %v, got %v", expect, result)
+	}
+	for n := range expect.Counts {
+		if result.Counts[n] != expect.Counts[n] {
+			t.Errorf("Bin %d: expected %d, got %d", n, expect.Counts[n], result.Counts[n])
+		}
+	}
+	if result_params == nil || result_params.ZF.Name != zf_klein.Name {
+		t.Errorf("Expected params to be read back, got %v", result_params)
+	}
+}
+
+func TestReadStoreRejectsBadHeaders(t *testing.T) {
+	write := func(header []byte, header_len uint32) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(STORE_MAGIC))                                //nolint:errcheck
+		binary.Write(zw, binary.LittleEndian, uint16(STORE_VERSION)) //nolint:errcheck
+		binary.Write(zw, binary.LittleEndian, header_len)            //nolint:errcheck
+		zw.Write(header)                                             //nolint:errcheck
+		zw.Close()                                                   //nolint:errcheck
+		path := filepath.Join(t.TempDir(), "bad.baes")
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	headers := map[string]string{
+		"negative": `{"view": [-1, -1, 1, 1], "width": -4, "height": 2}`,
+		"empty":    `{"view": [-1, -1, 1, 1], "width": 0, "height": 2}`,
+		"huge":     `{"view": [-1, -1, 1, 1], "width": 1000000, "height": 1000000}`,
+	}
+	for name, header := range headers {
+		if _, _, err := ReadStore(write([]byte(header), uint32(len(header)))); err == nil {
+			t.Errorf("Expected an error for a %s store", name)
+		}
+	}
+	if _, _, err := ReadStore(write(nil, 0xffffffff)); err == nil || !strings.Contains(err.Error(), "header") {
+		t.Errorf("Expected an error for a 4GB header, got %v", err)
+	}
+}
+
+func TestSampleStoreSaturates(t *testing.T) {
+	view := plane.PlaneView{Min: complex(-1, -1), Max: complex(1, 1)}
+	store := NewSampleStore(view, 1, 1)
+	store.Counts[0] = math.MaxUint32 - 1
+	store.Add(0)
+	store.Add(0)
+	if err := store.Merge(store); err != nil {
+		t.Fatal(err)
+	}
+	if store.Counts[0] != math.MaxUint32 {
+		t.Errorf("Expected the bin to saturate, got %d", store.Counts[0])
+	}
+}
+
+func TestSampleStoreMask(t *testing.T) {
+	params := NewCalcParams(CalcParams{
+		Plane:      plane.NewPlane(complex(0, 0), complex(4, 4), 4),
+		Style:      Attractor,
+		ZF:         zf_klein,
+		C:          complex(-0.1278, 0.0),
+		Iterations: 64,
+		CalcArea:   plane.PlaneView{Min: complex(-0.5, -0.25), Max: complex(-0.5, 0.5)},
+		RPoints:    1,
+		IPoints:    8,
+		Mask:       RectMask(image.Rect(0, 0, 2, 4)),
+	})
+	params.Store = NewPlaneSampleStore(params.Plane, 2)
+	histogram := params.Calculate(params.MakeProblemSet())
+
+	for n, count := range params.Store.Counts {
+		if xy := params.Plane.ToImagePoint(params.Store.center(n)); count > 0 && !params.Mask.Contains(xy) {
+			t.Errorf("Store bin at %v is outside the mask", xy)
+		}
+	}
+	if sum, stored := histogram.Sum(), params.Store.Rasterize(params.Plane).Sum(); sum == 0 || stored != sum {
+		t.Errorf("Expected the store to hold the %d masked samples, got %d", sum, stored)
+	}
+}
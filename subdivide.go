@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"time"
+
+	"github.com/brainsik/bae/plane"
+)
+
+const (
+	SUBDIVIDE_TILE = 64 // size of the tiles handed to each routine
+	SUBDIVIDE_MIN  = 4  // rectangles this small are calculated pixel by pixel
+)
+
+// CanSubdivide returns whether the image can be calculated with Mariani-Silver
+// rectangle subdivision instead of calculating every pixel. Filled pixels
+// would have copies of the border's CalcDetail, so recording it rules this out.
+func (cp *CalcParams) CanSubdivide() bool {
+	return cp.Subdivide && !cp.Style.Accumulates() && !cp.CF.Exact && !cp.RecordsDetail()
+}
+
+// CalculateSubdivided calculates the image with Mariani-Silver subdivision.
+// The image, or the Mask's bounds, is broken into tiles which are calculated concurrently. Each tile
+// has its border calculated; if every border pixel has the same result the
+// inside is filled without calculating it, otherwise the tile is split into
+// four and each part is tried again.
+func (cp *CalcParams) CalculateSubdivided() (histogram CalcResults) {
+	concurrency := cp.Concurrency
+	if cp.Concurrency == 0 {
+		concurrency = int(1.5 * float64(runtime.NumCPU()))
+	}
+
+	var tiles []image.Rectangle
+	bounds := cp.Plane.Image().Bounds()
+	if cp.Mask != nil {
+		bounds = bounds.Intersect(cp.Mask.Bounds())
+	}
+	for x := bounds.Min.X; x < bounds.Max.X; x += SUBDIVIDE_TILE {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += SUBDIVIDE_TILE {
+			tile := image.Rect(x, y, x+SUBDIVIDE_TILE, y+SUBDIVIDE_TILE)
+			tiles = append(tiles, tile.Intersect(bounds))
+		}
+	}
+	fmt.Printf("Tiles to subdivide: %d (using %v concurrent routines)\n", len(tiles), concurrency)
+
+	t_start := time.Now()
+	tile_ch := make(chan image.Rectangle)
+	result_ch := make(chan CalcResults)
+	calculated_ch := make(chan int)
+	for n := 0; n < concurrency; n++ {
+		go func() {
+			results := make(CalcResults)
+			calculated := 0
+			for tile := range tile_ch {
+				calculated += cp.subdivide(tile, results)
+			}
+			result_ch <- results
+			calculated_ch <- calculated
+		}()
+	}
+	for _, tile := range tiles {
+		tile_ch <- tile
+	}
+	close(tile_ch)
+
+	histogram = make(CalcResults)
+	calculated := 0
+	for n := 0; n < concurrency; n++ {
+		histogram.Merge(<-result_ch)
+		calculated += <-calculated_ch
+	}
+	for xy := range histogram {
+		if !cp.inMask(xy) {
+			delete(histogram, xy)
+		}
+	}
+
+	total := bounds.Dx() * bounds.Dy()
+	fmt.Printf("[%v] ✅ Subdivided %6.0fs • calculated %d of %d pixels (%.1f%%)\n",
+		TimestampMilli(), time.Since(t_start).Seconds(),
+		calculated, total, 100*float64(calculated)/float64(total))
+	return
+}
+
+// subdivide fills in the results for rect, returning the number of pixels calculated.
+func (cp *CalcParams) subdivide(rect image.Rectangle, histogram CalcResults) (calculated int) {
+	if rect.Empty() {
+		return
+	}
+
+	calc := func(x, y int) *CalcResult {
+		xy := plane.ImagePoint{X: x, Y: y}
+		if r, ok := histogram[xy]; ok {
+			return r
+		}
+		cp.orbit(CalcPoint{Z: cp.Plane.ToComplexPoint(xy), XY: xy}, histogram)
+		calculated++
+		return histogram[xy]
+	}
+
+	// Small rectangles are calculated pixel by pixel.
+	if rect.Dx() <= SUBDIVIDE_MIN || rect.Dy() <= SUBDIVIDE_MIN {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				calc(x, y)
+			}
+		}
+		return
+	}
+
+	// Calculate the border.
+	first := calc(rect.Min.X, rect.Min.Y)
+	uniform := true
+	check := func(x, y int) {
+		if !first.SameOutcome(calc(x, y)) {
+			uniform = false
+		}
+	}
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		check(x, rect.Min.Y)
+		check(x, rect.Max.Y-1)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		check(rect.Min.X, y)
+		check(rect.Max.X-1, y)
+	}
+
+	if uniform {
+		for x := rect.Min.X + 1; x < rect.Max.X-1; x++ {
+			for y := rect.Min.Y + 1; y < rect.Max.Y-1; y++ {
+				xy := plane.ImagePoint{X: x, Y: y}
+				histogram[xy] = first.Copy(cp.Plane.ToComplexPoint(xy))
+			}
+		}
+		return
+	}
+
+	// Split into quarters. The borders of the quarters that overlap the
+	// parent's border are already calculated.
+	mid := image.Point{(rect.Min.X + rect.Max.X) / 2, (rect.Min.Y + rect.Max.Y) / 2}
+	calculated += cp.subdivide(image.Rect(rect.Min.X, rect.Min.Y, mid.X, mid.Y), histogram)
+	calculated += cp.subdivide(image.Rect(mid.X, rect.Min.Y, rect.Max.X, mid.Y), histogram)
+	calculated += cp.subdivide(image.Rect(rect.Min.X, mid.Y, mid.X, rect.Max.Y), histogram)
+	calculated += cp.subdivide(image.Rect(mid.X, mid.Y, rect.Max.X, rect.Max.Y), histogram)
+	return
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"strings"
+
+	"github.com/brainsik/bae/plane"
+)
+
+// Symmetry is a set of reflections and rotations of the complex plane which
+// leave an escape-time image unchanged.
+type Symmetry uint
+
+const (
+	NoSymmetry         Symmetry = 1 << iota // never use symmetry, even if detected
+	ConjugateSymmetry                       // reflection about the real axis: z → z̄
+	RotationalSymmetry                      // half turn about the origin: z → -z
+	ImaginarySymmetry                       // reflection about the imaginary axis: z → -z̄
+)
+
+var SymmetryName = map[Symmetry]string{
+	NoSymmetry:         "None",
+	ConjugateSymmetry:  "Conjugate",
+	RotationalSymmetry: "Rotational",
+	ImaginarySymmetry:  "Imaginary",
+}
+
+// symmetryMaps are the plane transformations for each symmetry.
+var symmetryMaps = map[Symmetry]func(complex128) complex128{
+	ConjugateSymmetry:  cmplx.Conj,
+	RotationalSymmetry: func(z complex128) complex128 { return -z },
+	ImaginarySymmetry:  func(z complex128) complex128 { return -cmplx.Conj(z) },
+}
+
+func (s Symmetry) String() string {
+	if s == 0 {
+		return "Auto"
+	}
+	var names []string
+	for _, sym := range []Symmetry{NoSymmetry, ConjugateSymmetry, RotationalSymmetry, ImaginarySymmetry} {
+		if s&sym != 0 {
+			names = append(names, SymmetryName[sym])
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Symmetries returns the symmetries that will be used for the calculation.
+// When Symmetry is zero they are detected. Accumulating styles never use
+// symmetry, nor does recording CalcDetail since the final z of a mirrored
+// orbit isn't always the mirror image of the final z.
+func (cp *CalcParams) Symmetries() Symmetry {
+	sym := cp.Symmetry
+	switch {
+	case cp.Style.Accumulates() || cp.RecordsDetail() || sym&NoSymmetry != 0:
+		return 0
+	case sym == 0:
+		sym = cp.DetectSymmetry()
+	}
+
+	// Any two of the symmetries compose to make the third.
+	if sym != 0 && sym != ConjugateSymmetry && sym != RotationalSymmetry && sym != ImaginarySymmetry {
+		sym = ConjugateSymmetry | RotationalSymmetry | ImaginarySymmetry
+	}
+	return sym
+}
+
+// DetectSymmetry probes the ZFunc at random points to find which symmetries
+// the escape-time image has. A map g is a symmetry when every orbit starting
+// at g(z) is g applied to the orbit starting at z, or the two orbits join
+// after the first iteration. Either way the escape times are the same since
+// |g(z)| = |z|.
+func (cp *CalcParams) DetectSymmetry() (sym Symmetry) {
+	const probes = 64
+	rng := rand.New(rand.NewSource(1))
+	scale := 2 * math.Max(cp.Limit, 1)
+	random := func() complex128 {
+		return complex(scale*(rng.Float64()-0.5), scale*(rng.Float64()-0.5))
+	}
+	close_to := func(a, b complex128) bool {
+		return cmplx.Abs(a-b) <= 1e-9*(1+cmplx.Abs(a))
+	}
+
+	for s, g := range symmetryMaps {
+		equivariant, invariant := true, true
+		for n := 0; n < probes; n++ {
+			var z, c, gz, gc complex128
+			if cp.Style == Mandelbrot {
+				// the starting point 0 is fixed by every map so c moves with z
+				z, c = random(), random()
+				gz, gc = g(z), g(c)
+			} else {
+				z, c = random(), cp.C
+				gz, gc = g(z), c
+			}
+			f := cp.ZF.F(z, c)
+			f_g := cp.ZF.F(gz, gc)
+			equivariant = equivariant && close_to(f_g, g(f))
+			invariant = invariant && cp.Style != Mandelbrot && close_to(f_g, f)
+		}
+		if equivariant || invariant {
+			sym |= s
+		}
+	}
+	return
+}
+
+// MakeSymmetricProblemSet removes points from the problem set that are mirror
+// images of other points. The returned mirrors map each point that will be
+// calculated to the points which should receive a copy of its result.
+//
+// Only points whose mirror image lands exactly on a pixel inside the image
+// are removed, so off-centre and inverted views are handled by calculating
+// whatever part of the view has no mirror image.
+func (cp *CalcParams) MakeSymmetricProblemSet(problems []CalcPoint, sym Symmetry) (
+	unique []CalcPoint, mirrors map[plane.ImagePoint][]CalcPoint,
+) {
+	mirrors = make(map[plane.ImagePoint][]CalcPoint)
+	if sym == 0 {
+		return problems, mirrors
+	}
+
+	width, height := cp.Plane.ImageWidth(), cp.Plane.ImageHeight()
+	view := cp.Plane.View()
+	tolerance := 1e-6 * math.Min(view.RealLen()/float64(width), view.ImagLen()/float64(height))
+
+	covered := make(map[plane.ImagePoint]bool, len(problems))
+	for _, pt := range problems {
+		if covered[pt.XY] {
+			continue
+		}
+		covered[pt.XY] = true
+		unique = append(unique, pt)
+
+		for s, g := range symmetryMaps {
+			if sym&s == 0 {
+				continue
+			}
+			m := g(pt.Z)
+			xy := cp.Plane.ToImagePoint(m)
+			if xy.X < 0 || xy.X >= width || xy.Y < 0 || xy.Y >= height || covered[xy] || !cp.inMask(xy) {
+				continue
+			}
+			if cmplx.Abs(cp.Plane.ToComplexPoint(xy)-m) > tolerance {
+				continue // not aligned with the pixel grid
+			}
+			covered[xy] = true
+			mirrors[pt.XY] = append(mirrors[pt.XY], CalcPoint{Z: cp.Plane.ToComplexPoint(xy), XY: xy})
+		}
+	}
+
+	fmt.Printf("Symmetry %v: calculating %d of %d points\n", sym, len(unique), len(problems))
+	return
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/brainsik/bae/plane"
+)
+
+func TestDetectSymmetry(t *testing.T) {
+	testCases := []struct {
+		name   string
+		style  CalcStyle
+		zf     ZFunc
+		c      complex128
+		expect Symmetry
+	}{
+		{"mandelbrot", Mandelbrot, zf_mandelbrot, 0, ConjugateSymmetry},
+		{"julia-real-c", Julia, zf_mandelbrot, complex(-0.8, 0), ConjugateSymmetry | RotationalSymmetry | ImaginarySymmetry},
+		{"julia-complex-c", Julia, zf_mandelbrot, complex(0.285, 0.01), RotationalSymmetry},
+		{"burning-ship", Mandelbrot, zf_burning_ship, 0, 0},
+		{"klein", Julia, zf_klein, complex(-0.1278, 0), 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := CalcParams{Style: tc.style, ZF: tc.zf, C: tc.c, Limit: 2}
+			result := params.Symmetries()
+			if result != tc.expect {
+				t.Errorf("Expected %v, got %v", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestSymmetriesOverride(t *testing.T) {
+	params := CalcParams{Style: Mandelbrot, ZF: zf_mandelbrot, Limit: 2, Symmetry: NoSymmetry}
+	if result := params.Symmetries(); result != 0 {
+		t.Errorf("Expected no symmetry, got %v", result)
+	}
+
+	params.Symmetry = ConjugateSymmetry | RotationalSymmetry
+	expect := ConjugateSymmetry | RotationalSymmetry | ImaginarySymmetry
+	if result := params.Symmetries(); result != expect {
+		t.Errorf("Expected %v, got %v", expect, result)
+	}
+}
+
+func TestSymmetricCalculationMatches(t *testing.T) {
+	testCases := []struct {
+		name  string
+		plane *plane.Plane
+	}{
+		{"centred", plane.NewPlane(complex(0, 0), complex(3, 3), 40)},
+		{"off-centre", plane.NewPlane(complex(0.3, -0.45), complex(3, 3), 40)},
+		{"inverted", plane.NewPlane(complex(0, 0.3), complex(3, 3), 40).WithInverted()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := CalcParams{
+				Plane:      tc.plane,
+				Style:      Julia,
+				ZF:         zf_mandelbrot,
+				C:          complex(-0.8, 0),
+				Iterations: 64,
+				Limit:      2,
+			}
+			expect := params.CalculateProblems(params.MakeImageProblemSet())
+			result := params.CalculateParallel()
+
+			if len(result) != len(expect) {
+				t.Fatalf("len(result) = %d; want %d", len(result), len(expect))
+			}
+			for xy, e := range expect {
+				r, ok := result[xy]
+				if !ok {
+					t.Fatalf("Missing result for %v", xy)
+				}
+				if r.Val != e.Val || r.Escaped != e.Escaped || r.Z != e.Z {
+					t.Errorf("Result at %v differs: %v != %v", xy, *r, *e)
+				}
+			}
+		})
+	}
+}
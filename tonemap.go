@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ToneMap is the curve mapping densities to brightness before gamma correction.
+type ToneMap int
+
+const (
+	LinearTone   ToneMap = iota // clip at the white point
+	LogTone                     // log density, for histograms spanning orders of magnitude
+	AsinhTone                   // linear for small densities and logarithmic for large ones
+	ReinhardTone                // highlights roll off, reaching white at the white point
+	ACESTone                    // filmic S curve
+)
+
+var ToneMapName = map[int]string{
+	int(LinearTone):   "linear",
+	int(LogTone):      "log",
+	int(AsinhTone):    "asinh",
+	int(ReinhardTone): "reinhard",
+	int(ACESTone):     "aces",
+}
+
+func (tm ToneMap) String() string {
+	return ToneMapName[int(tm)]
+}
+
+func (tm ToneMap) MarshalText() ([]byte, error) {
+	return []byte(tm.String()), nil
+}
+
+func (tm *ToneMap) UnmarshalText(text []byte) error {
+	for n, name := range ToneMapName {
+		if name == string(text) {
+			*tm = ToneMap(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tone map: %q", text)
+}
+
+// Map returns the brightness from 0 to 1 of val, where max is the value a
+// ColorFunc normalizes to (e.g. the clip) and val reaches white at white
+// times max.
+func (tm ToneMap) Map(val, max, white float64) float64 {
+	var t float64
+	switch tm {
+	case LogTone:
+		t = math.Log1p(val) / math.Log1p(white*max)
+	case AsinhTone:
+		t = math.Asinh(val) / math.Asinh(white*max)
+	case ReinhardTone:
+		x := val / max
+		t = x * (1 + x/(white*white)) / (1 + x)
+	case ACESTone:
+		// Krzysztof Narkowicz's fit of the ACES curve, scaled so white is 1.
+		aces := func(x float64) float64 { return x * (2.51*x + 0.03) / (x*(2.43*x+0.59) + 0.14) }
+		t = aces(val/max) / aces(white)
+	default:
+		t = val / (white * max)
+	}
+	return math.Min(t, 1)
+}
+
+// Scale returns the tone mapped and gamma corrected brightness of val, where
+// max is the value the ColorFunc normalizes to. Exposure is in stops.
+func (cfp ColorFuncParams) Scale(val, max, gamma float64) float64 {
+	return GammaScale(cfp.Tone.Map(val*math.Exp2(cfp.Exposure), max, cfp.white()), 1, gamma)
+}
+
+// WhiteAt returns the value which Scale brings to white, where max is the
+// value the ColorFunc normalizes to. Every tone map reaches white at the
+// white point, so it only depends on the exposure and white.
+func (cfp ColorFuncParams) WhiteAt(max float64) float64 {
+	return cfp.white() * max / math.Exp2(cfp.Exposure)
+}
+
+func (cfp ColorFuncParams) white() float64 {
+	if cfp.White <= 0 {
+		return 1
+	}
+	return cfp.White
+}
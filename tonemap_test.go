@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScaleLinearMatchesGammaScale(t *testing.T) {
+	cfp := ColorFuncParams{}
+	for _, val := range []float64{0, 1, 7, 50, 99, 100, 1000} {
+		for _, gamma := range []float64{0, 1, 2.2} {
+			if result, expect := cfp.Scale(val, 100, gamma), GammaScale(val, 100, gamma); result != expect {
+				t.Errorf("Scale(%v, 100, %v) = %v; want %v", val, gamma, result, expect)
+			}
+		}
+	}
+}
+
+func TestToneMapCurves(t *testing.T) {
+	const max, white = 100.0, 4.0
+	for n := range ToneMapName {
+		tm := ToneMap(n)
+		if result := tm.Map(0, max, white); result != 0 {
+			t.Errorf("%v: Map(0) = %v; want 0", tm, result)
+		}
+		if result := tm.Map(white*max, max, white); math.Abs(result-1) > 1e-12 {
+			t.Errorf("%v: Map(white) = %v; want 1", tm, result)
+		}
+		if result := tm.Map(10*white*max, max, white); result != 1 {
+			t.Errorf("%v: Map(past white) = %v; want 1", tm, result)
+		}
+		last := 0.0
+		for val := 1.0; val < white*max; val *= 1.5 {
+			result := tm.Map(val, max, white)
+			if result <= last {
+				t.Errorf("%v: Map(%v) = %v, not above %v", tm, val, result, last)
+			}
+			last = result
+		}
+	}
+}
+
+func TestToneMapLogLiftsLowDensities(t *testing.T) {
+	linear := LinearTone.Map(10, 1e6, 1)
+	for _, tm := range []ToneMap{LogTone, AsinhTone} {
+		if result := tm.Map(10, 1e6, 1); result < 100*linear {
+			t.Errorf("%v: Map(10) = %v; want much more than linear %v", tm, result, linear)
+		}
+	}
+}
+
+func TestScaleExposure(t *testing.T) {
+	cfp := ColorFuncParams{Exposure: 1}
+	if result, expect := cfp.Scale(25, 100, 1), 0.5; result != expect {
+		t.Errorf("Scale with +1 stop = %v; want %v", result, expect)
+	}
+}
@@ -6,40 +6,103 @@ import (
 	"math/cmplx"
 )
 
-// ZFunc represents the math function f(z, c).
+// Float is either precision of floating point number.
+type Float interface {
+	float32 | float64
+}
+
+// Formula is f(z, c) on the real and imaginary parts of z and c.
+type Formula[F Float] func(x, y, cx, cy F) (F, F)
+
+// ZFunc represents the math function f(z, c). F32 is the same formula on
+// float32 parts, so orbits can be iterated in float32 for quick previews.
 type ZFunc struct {
+	Name string
 	Desc string
 	F    func(z, c complex128) complex128
+	F32  Formula[float32]
 }
 
 func (zf ZFunc) String() string {
 	return fmt.Sprintf("ZFunc: %s", zf.Desc)
 }
 
+// F64 is F as a Formula, giving exactly the same results.
+func (zf ZFunc) F64(x, y, cx, cy float64) (float64, float64) {
+	z := zf.F(complex(x, y), complex(cx, cy))
+	return real(z), imag(z)
+}
+
+// burningShipF is (|x| + i|y|)^2 + c.
+func burningShipF[F Float](x, y, cx, cy F) (F, F) {
+	return x*x - y*y + cx, 2*abs(x*y) + cy
+}
+
+// kleinF is z^2 - y + i|x| + c.
+func kleinF[F Float](x, y, cx, cy F) (F, F) {
+	return x*x - y*y - y + cx, 2*x*y + abs(x) + cy
+}
+
+// klein2F is z^2 + |y| + ix + c.
+func klein2F[F Float](x, y, cx, cy F) (F, F) {
+	return x*x - y*y + abs(y) + cx, 2*x*y + x + cy
+}
+
+// mandelbrotF is z^2 + c.
+func mandelbrotF[F Float](x, y, cx, cy F) (F, F) {
+	return x*x - y*y + cx, 2*x*y + cy
+}
+
 var zf_burning_ship = ZFunc{ //nolint:unused
+	Name: "burning_ship",
 	Desc: `Burning Ship: (|x| + i|y|)^2 + c`,
 	F: func(z, c complex128) complex128 {
 		return cmplx.Pow(complex(math.Abs(real(z)), math.Abs(imag(z))), 2.0) + c
 	},
+	F32: burningShipF[float32],
 }
 
 var zf_klein = ZFunc{ //nolint:unused
+	Name: "klein",
 	Desc: `Klein: z^2 - y + i|x| + c`,
 	F: func(z, c complex128) complex128 {
 		return cmplx.Pow(z, 2.0) + complex(-imag(z), math.Abs(real(z))) + c
 	},
+	F32: kleinF[float32],
 }
 
 var zf_klein2 = ZFunc{ //nolint:unused
+	Name: "klein2",
 	Desc: `Klein: z^2 + |y| + ix + c`,
 	F: func(z, c complex128) complex128 {
 		return cmplx.Pow(z, 2.0) + complex(math.Abs(imag(z)), real(z)) + c
 	},
+	F32: klein2F[float32],
 }
 
 var zf_mandelbrot = ZFunc{ //nolint:unused
+	Name: "mandelbrot",
 	Desc: `Mandelbrot: z^2 + c`,
 	F: func(z, c complex128) complex128 {
 		return cmplx.Pow(z, 2.0) + c
 	},
+	F32: mandelbrotF[float32],
+}
+
+// abs returns the absolute value in either precision.
+func abs[F Float](x F) F {
+	return F(math.Abs(float64(x)))
+}
+
+// toComplex returns the point with real and imaginary parts x and y.
+func toComplex[F Float](x, y F) complex128 {
+	return complex(float64(x), float64(y))
+}
+
+// ZFuncs are the known ZFuncs by name.
+var ZFuncs = map[string]ZFunc{
+	zf_burning_ship.Name: zf_burning_ship,
+	zf_klein.Name:        zf_klein,
+	zf_klein2.Name:       zf_klein2,
+	zf_mandelbrot.Name:   zf_mandelbrot,
 }